@@ -8,6 +8,7 @@ import (
 	"time"
 
 	ping "github.com/digineo/go-ping"
+	"github.com/digineo/go-ping/monitor"
 )
 
 type history struct {
@@ -31,6 +32,9 @@ type stat struct {
 	worst   time.Duration
 	mean    time.Duration
 	stddev  time.Duration
+	jitter  time.Duration // RFC 3550 interarrival jitter
+	ipdv    time.Duration // mean absolute difference of consecutive rtts
+	mos     float64       // E-model voice quality estimate for opts.codec
 }
 
 func (u *destination) ping(pinger *ping.Pinger) {
@@ -59,6 +63,7 @@ func (s *history) compute() (st stat) {
 	if s.received == 0 {
 		if s.lost > 0 {
 			st.pktLoss = 1.0
+			st.mos = 1
 		}
 		return
 	}
@@ -97,5 +102,27 @@ func (s *history) compute() (st stat) {
 	}
 	st.stddev = time.Duration(math.Sqrt(stddevNum / float64(size)))
 
+	// delay variation in sample order, starting with the oldest result
+	oldest := 0
+	if s.received > size {
+		oldest = s.received % size
+	}
+	var jitter, ipdv float64
+	for i := 1; i < size; i++ {
+		d := math.Abs(float64(collection[(oldest+i)%size] - collection[(oldest+i-1)%size]))
+		jitter += (d - jitter) / 16
+		ipdv += d
+	}
+	if size > 1 {
+		st.jitter = time.Duration(jitter)
+		st.ipdv = time.Duration(ipdv / float64(size-1))
+	}
+
+	_, st.mos = monitor.EModel(opts.codec, ms(st.mean), ms(st.jitter), st.pktLoss)
+
 	return
 }
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
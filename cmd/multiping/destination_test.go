@@ -115,3 +115,19 @@ func TestComputeStats(t *testing.T) {
 		assert.InDelta(tc.loss, subject.pktLoss, 0.0001, "test case #%d (%s): pktLoss", i, tc.title)
 	}
 }
+
+func TestComputeDelayVariation(t *testing.T) {
+	assert := assert.New(t)
+	const ms = time.Millisecond
+
+	// the ring starts at received%len: 20ms, 40ms, 80ms
+	h := history{received: 4, results: []time.Duration{80 * ms, 20 * ms, 40 * ms}}
+	st := h.compute()
+	assert.Equal(30*ms, st.ipdv)
+	assert.Equal(3671875*time.Nanosecond, st.jitter)
+
+	h = history{received: 1, results: []time.Duration{ms, 0}}
+	st = h.compute()
+	assert.Zero(st.ipdv)
+	assert.Zero(st.jitter)
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	ping "github.com/digineo/go-ping"
+	"github.com/digineo/go-ping/monitor"
 )
 
 var opts = struct {
@@ -19,6 +20,7 @@ var opts = struct {
 	bind6           string
 	dests           []*destination
 	resolverTimeout time.Duration
+	codec           monitor.Codec
 }{
 	timeout:         1000 * time.Millisecond,
 	interval:        1000 * time.Millisecond,
@@ -27,6 +29,7 @@ var opts = struct {
 	payloadSize:     56,
 	statBufferSize:  50,
 	resolverTimeout: 1500 * time.Millisecond,
+	codec:           monitor.CodecG711,
 }
 
 var (
@@ -47,8 +50,16 @@ func main() {
 	flag.StringVar(&opts.bind4, "bind4", opts.bind4, "IPv4 bind address")
 	flag.StringVar(&opts.bind6, "bind6", opts.bind6, "IPv6 bind address")
 	flag.DurationVar(&opts.resolverTimeout, "resolve", opts.resolverTimeout, "timeout for DNS lookups")
+	codec := flag.String("codec", opts.codec.Name, "codec profile for the MOS column (G.711, G.729A or GSM-EFR)")
 	flag.Parse()
 
+	if c, err := monitor.CodecByName(*codec); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	} else {
+		opts.codec = c
+	}
+
 	log.SetFlags(0)
 
 	for _, host := range flag.Args() {
@@ -68,6 +68,26 @@ var coldef = [...]struct {
 		align:   tview.AlignRight,
 		content: func(st *stat) string { return ts(st.stddev) },
 	},
+	{
+		title:   "jitter",
+		align:   tview.AlignRight,
+		content: func(st *stat) string { return ts(st.jitter) },
+	},
+	{
+		title:   "ipdv",
+		align:   tview.AlignRight,
+		content: func(st *stat) string { return ts(st.ipdv) },
+	},
+	{
+		title: "mos",
+		align: tview.AlignRight,
+		content: func(st *stat) string {
+			if st.mos == 0 {
+				return "n/a"
+			}
+			return fmt.Sprintf("%0.2f", st.mos)
+		},
+	},
 }
 
 func buildTUI(destinations []*destination) *userInterface {
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/digineo/go-ping/monitor"
+)
+
+// defaultBuckets are the upper bounds (in seconds) of the RTT histogram.
+var defaultBuckets = []float64{
+	0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5,
+}
+
+// metricsSource is implemented by *monitor.Monitor.
+type metricsSource interface {
+	Export() map[string]*monitor.Metrics
+}
+
+type label struct {
+	name  string
+	value string
+}
+
+// series holds the cumulative values for a single target. In contrast
+// to the gauges, these are never reset.
+type series struct {
+	labels  string   // pre-rendered label set
+	sent    uint64   // number of echo requests sent
+	lost    uint64   // number of echo requests without reply
+	buckets []uint64 // number of observations per bucket (not cumulative)
+	count   uint64   // number of RTT observations
+	sum     float64  // sum of RTT observations in seconds
+}
+
+// collector renders the monitor metrics in the Prometheus text
+// exposition format.
+type collector struct {
+	source  metricsSource
+	buckets []float64
+	series  map[string]*series
+	mtx     sync.Mutex
+}
+
+func newCollector(source metricsSource, buckets []float64) *collector {
+	return &collector{
+		source:  source,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+}
+
+// addTarget registers a target. It must be called before the target
+// is added to the monitor.
+func (c *collector) addTarget(key string, addr net.IPAddr, labels []label) {
+	all := append([]label{
+		{name: "target", value: key},
+		{name: "address", value: addr.String()},
+	}, labels...)
+
+	parts := make([]string, len(all))
+	for i, l := range all {
+		parts[i] = l.name + `="` + escapeLabelValue(l.value) + `"`
+	}
+
+	c.mtx.Lock()
+	c.series[key] = &series{
+		labels:  strings.Join(parts, ","),
+		buckets: make([]uint64, len(c.buckets)),
+	}
+	c.mtx.Unlock()
+}
+
+// observe updates the counters and the histogram for the given target.
+// It is meant to be used as monitor.Monitor.OnResult.
+func (c *collector) observe(key string, res monitor.Result) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	s := c.series[key]
+	if s == nil {
+		return
+	}
+
+	s.sent++
+	if res.Lost {
+		s.lost++
+		return
+	}
+
+	rtt := res.RTT.Seconds()
+	s.count++
+	s.sum += rtt
+	if i := sort.SearchFloat64s(c.buckets, rtt); i < len(s.buckets) {
+		s.buckets[i]++
+	}
+}
+
+var gauges = [...]struct {
+	name  string
+	help  string
+	value func(*monitor.Metrics) float64
+}{
+	{"ping_packets_sent", "Number of echo requests in the current history window.", func(m *monitor.Metrics) float64 { return float64(m.PacketsSent) }},
+	{"ping_packets_lost", "Number of lost echo requests in the current history window.", func(m *monitor.Metrics) float64 { return float64(m.PacketsLost) }},
+	{"ping_history_start_timestamp_seconds", "Send time of the oldest echo request in the current history window.", func(m *monitor.Metrics) float64 { return float64(m.Start.UnixNano()) / 1e9 }},
+	{"ping_rtt_best_seconds", "Best round trip time in the current history window.", func(m *monitor.Metrics) float64 { return msToSeconds(m.Best) }},
+	{"ping_rtt_worst_seconds", "Worst round trip time in the current history window.", func(m *monitor.Metrics) float64 { return msToSeconds(m.Worst) }},
+	{"ping_rtt_median_seconds", "Median round trip time in the current history window.", func(m *monitor.Metrics) float64 { return msToSeconds(m.Median) }},
+	{"ping_rtt_mean_seconds", "Mean round trip time in the current history window.", func(m *monitor.Metrics) float64 { return msToSeconds(m.Mean) }},
+	{"ping_rtt_stddev_seconds", "Standard deviation of the round trip time in the current history window.", func(m *monitor.Metrics) float64 { return msToSeconds(m.StdDev) }},
+	{"ping_rtt_jitter_seconds", "RFC 3550 interarrival jitter of the round trip time in the current history window.", func(m *monitor.Metrics) float64 { return msToSeconds(m.Jitter) }},
+	{"ping_rtt_ipdv_mean_seconds", "Mean round trip time difference of consecutive replies in the current history window.", func(m *monitor.Metrics) float64 { return msToSeconds(m.IPDVMean) }},
+	{"ping_rtt_ipdv_max_seconds", "Maximum round trip time difference of consecutive replies in the current history window.", func(m *monitor.Metrics) float64 { return msToSeconds(m.IPDVMax) }},
+	{"ping_voice_r_factor", "E-model R-factor of the current history window.", func(m *monitor.Metrics) float64 { return float64(m.RFactor) }},
+	{"ping_voice_mos", "E-model mean opinion score of the current history window.", func(m *monitor.Metrics) float64 { return float64(m.MOS) }},
+	{"ping_loss_bursts", "Number of runs of consecutive losses in the current history window.", func(m *monitor.Metrics) float64 { return float64(m.LossBursts) }},
+	{"ping_loss_burst_longest", "Longest run of consecutive losses in the current history window.", func(m *monitor.Metrics) float64 { return float64(m.LongestBurst) }},
+	{"ping_availability_ratio", "Fraction of echo requests in the current history window not part of an outage.", func(m *monitor.Metrics) float64 { return float64(m.Availability) / 100 }},
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	metrics := c.source.Export()
+	buf := bufio.NewWriter(w)
+	defer buf.Flush()
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	keys := make([]string, 0, len(c.series))
+	for key := range c.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, g := range gauges {
+		fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+		for _, key := range keys {
+			if m := metrics[key]; m != nil {
+				fmt.Fprintf(buf, "%s{%s} %s\n", g.name, c.series[key].labels, formatFloat(g.value(m)))
+			}
+		}
+	}
+
+	header := false
+	for _, key := range keys {
+		m := metrics[key]
+		if m == nil {
+			continue
+		}
+		for _, q := range m.Quantiles {
+			if !header {
+				fmt.Fprint(buf, "# HELP ping_rtt_quantile_seconds Round trip time quantiles in the current history window.\n# TYPE ping_rtt_quantile_seconds gauge\n")
+				header = true
+			}
+			fmt.Fprintf(buf, "ping_rtt_quantile_seconds{%s,quantile=\"%s\"} %s\n", c.series[key].labels, formatFloat(q.Q), formatFloat(msToSeconds(q.Value)))
+		}
+	}
+
+	fmt.Fprint(buf, "# HELP ping_sent_total Total number of echo requests sent.\n# TYPE ping_sent_total counter\n")
+	for _, key := range keys {
+		s := c.series[key]
+		fmt.Fprintf(buf, "ping_sent_total{%s} %d\n", s.labels, s.sent)
+	}
+
+	fmt.Fprint(buf, "# HELP ping_lost_total Total number of echo requests without reply.\n# TYPE ping_lost_total counter\n")
+	for _, key := range keys {
+		s := c.series[key]
+		fmt.Fprintf(buf, "ping_lost_total{%s} %d\n", s.labels, s.lost)
+	}
+
+	fmt.Fprint(buf, "# HELP ping_rtt_seconds Round trip time of answered echo requests.\n# TYPE ping_rtt_seconds histogram\n")
+	for _, key := range keys {
+		s := c.series[key]
+		var cumulative uint64
+		for i, le := range c.buckets {
+			cumulative += s.buckets[i]
+			fmt.Fprintf(buf, "ping_rtt_seconds_bucket{%s,le=\"%s\"} %d\n", s.labels, formatFloat(le), cumulative)
+		}
+		fmt.Fprintf(buf, "ping_rtt_seconds_bucket{%s,le=\"+Inf\"} %d\n", s.labels, s.count)
+		fmt.Fprintf(buf, "ping_rtt_seconds_sum{%s} %s\n", s.labels, formatFloat(s.sum))
+		fmt.Fprintf(buf, "ping_rtt_seconds_count{%s} %d\n", s.labels, s.count)
+	}
+}
+
+// parseBuckets parses a comma separated list of bucket boundaries in
+// seconds or as durations (e.g. "0.001,5ms,0.1").
+func parseBuckets(s string) ([]float64, error) {
+	var buckets []float64
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			d, derr := time.ParseDuration(field)
+			if derr != nil {
+				return nil, fmt.Errorf("invalid bucket %q", field)
+			}
+			v = d.Seconds()
+		}
+		if n := len(buckets); n > 0 && v <= buckets[n-1] {
+			return nil, fmt.Errorf("buckets must be in increasing order, got %q", field)
+		}
+		buckets = append(buckets, v)
+	}
+	return buckets, nil
+}
+
+// parseQuantiles parses a comma separated list of quantiles.
+func parseQuantiles(s string) ([]float64, error) {
+	var quantiles []float64
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		q, err := strconv.ParseFloat(field, 64)
+		if err != nil || q < 0 || q > 1 {
+			return nil, fmt.Errorf("invalid quantile %q", field)
+		}
+		quantiles = append(quantiles, q)
+	}
+	return quantiles, nil
+}
+
+func msToSeconds(ms float32) float64 {
+	return float64(ms) / 1000
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
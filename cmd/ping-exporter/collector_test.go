@@ -0,0 +1,129 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/digineo/go-ping/monitor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticSource map[string]*monitor.Metrics
+
+func (s staticSource) Export() map[string]*monitor.Metrics {
+	return s
+}
+
+func TestCollector(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	source := staticSource{
+		"a": &monitor.Metrics{PacketsSent: 3, PacketsLost: 1, Best: 1, Worst: 3, Median: 2, Mean: 2, StdDev: 1, Quantiles: []monitor.Quantile{{Q: 0.99, Value: 3}}},
+	}
+	c := newCollector(source, []float64{0.001, 0.01})
+	c.addTarget("a", net.IPAddr{IP: net.ParseIP("192.0.2.1")}, []label{{name: "site", value: `f"ra`}})
+	c.addTarget("b", net.IPAddr{IP: net.ParseIP("2001:db8::1")}, nil)
+
+	c.observe("a", monitor.Result{RTT: 500 * time.Microsecond})
+	c.observe("a", monitor.Result{RTT: 5 * time.Millisecond})
+	c.observe("a", monitor.Result{RTT: 50 * time.Millisecond})
+	c.observe("a", monitor.Result{Lost: true})
+	c.observe("b", monitor.Result{Lost: true})
+	c.observe("unknown", monitor.Result{})
+
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/metrics")
+	require.NoError(err)
+	defer res.Body.Close()
+	assert.Equal(http.StatusOK, res.StatusCode)
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(err)
+	lines := strings.Split(string(body), "\n")
+
+	const a = `target="a",address="192.0.2.1",site="f\"ra"`
+	const b = `target="b",address="2001:db8::1"`
+
+	for _, expected := range []string{
+		"# TYPE ping_packets_sent gauge",
+		"ping_packets_sent{" + a + "} 3",
+		"ping_packets_lost{" + a + "} 1",
+		"ping_rtt_median_seconds{" + a + "} 0.002",
+		"# TYPE ping_rtt_quantile_seconds gauge",
+		"ping_rtt_quantile_seconds{" + a + `,quantile="0.99"} 0.003`,
+		"# TYPE ping_sent_total counter",
+		"ping_sent_total{" + a + "} 4",
+		"ping_sent_total{" + b + "} 1",
+		"ping_lost_total{" + a + "} 1",
+		"ping_lost_total{" + b + "} 1",
+		"# TYPE ping_rtt_seconds histogram",
+		"ping_rtt_seconds_bucket{" + a + `,le="0.001"} 1`,
+		"ping_rtt_seconds_bucket{" + a + `,le="0.01"} 2`,
+		"ping_rtt_seconds_bucket{" + a + `,le="+Inf"} 3`,
+		"ping_rtt_seconds_sum{" + a + "} 0.0555",
+		"ping_rtt_seconds_count{" + a + "} 3",
+		"ping_rtt_seconds_count{" + b + "} 0",
+	} {
+		assert.Contains(lines, expected)
+	}
+
+	// no gauges without data
+	assert.NotContains(string(body), "ping_packets_sent{"+b)
+}
+
+func TestParseTargets(t *testing.T) {
+	assert := assert.New(t)
+
+	specs, err := parseTargets(strings.NewReader("# comment\n\n192.0.2.1 site=fra role=core\nexample.com\n"))
+	assert.NoError(err)
+	assert.Equal([]targetSpec{
+		{host: "192.0.2.1", labels: []label{{"site", "fra"}, {"role", "core"}}},
+		{host: "example.com"},
+	}, specs)
+
+	for _, input := range []string{
+		"192.0.2.1 site",
+		"192.0.2.1 1site=fra",
+		"192.0.2.1 target=foo",
+		"192.0.2.1 le=0.1",
+		"192.0.2.1 quantile=0.5",
+		"192.0.2.1 site=a site=b",
+	} {
+		_, err := parseTargets(strings.NewReader(input))
+		assert.Error(err, input)
+	}
+}
+
+func TestParseBuckets(t *testing.T) {
+	assert := assert.New(t)
+
+	buckets, err := parseBuckets("0.001, 5ms,0.1")
+	assert.NoError(err)
+	assert.Equal([]float64{0.001, 0.005, 0.1}, buckets)
+
+	_, err = parseBuckets("0.1,0.01")
+	assert.Error(err)
+
+	_, err = parseBuckets("fast")
+	assert.Error(err)
+}
+
+func TestParseQuantiles(t *testing.T) {
+	assert := assert.New(t)
+
+	quantiles, err := parseQuantiles("0.5, 0.99,0.999")
+	assert.NoError(err)
+	assert.Equal([]float64{0.5, 0.99, 0.999}, quantiles)
+
+	_, err = parseQuantiles("99")
+	assert.Error(err)
+}
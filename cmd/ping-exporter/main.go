@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/digineo/go-ping"
+	"github.com/digineo/go-ping/monitor"
+)
+
+var (
+	listen            = ":9427"
+	pingInterval      = 5 * time.Second
+	pingTimeout       = 4 * time.Second
+	historySize       = 10
+	size         uint = 56
+	bind4             = "0.0.0.0"
+	bind6             = "::"
+	rebind       bool
+	targetFile   string
+	buckets      string
+	quantiles    string
+	codec        = monitor.CodecG711.Name
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[options] [host [host [...]]]")
+		flag.PrintDefaults()
+	}
+
+	flag.StringVar(&listen, "listen", listen, "address to serve /metrics on")
+	flag.DurationVar(&pingInterval, "pingInterval", pingInterval, "interval for ICMP echo requests")
+	flag.DurationVar(&pingTimeout, "pingTimeout", pingTimeout, "timeout for ICMP echo request")
+	flag.IntVar(&historySize, "historySize", historySize, "number of results per target used for the gauges")
+	flag.UintVar(&size, "size", size, "size of additional payload data")
+	flag.StringVar(&bind4, "bind4", bind4, "IPv4 bind address")
+	flag.StringVar(&bind6, "bind6", bind6, "IPv6 bind address")
+	flag.BoolVar(&rebind, "rebind", rebind, "rebind when the bind addresses change (Linux only)")
+	flag.StringVar(&targetFile, "targets", "", "file with one target per line, optionally followed by name=value labels")
+	flag.StringVar(&buckets, "buckets", "", "comma separated upper bounds of the RTT histogram buckets in seconds")
+	flag.StringVar(&quantiles, "quantiles", "", "comma separated RTT quantiles of the history window, e.g. 0.9,0.99")
+	flag.StringVar(&codec, "codec", codec, "codec profile for the voice quality gauges (G.711, G.729A or GSM-EFR)")
+	flag.Parse()
+
+	var specs []targetSpec
+	for _, host := range flag.Args() {
+		specs = append(specs, targetSpec{host: host})
+	}
+	if targetFile != "" {
+		fromFile, err := readTargets(targetFile)
+		if err != nil {
+			log.Fatalf("unable to read targets: %v", err)
+		}
+		specs = append(specs, fromFile...)
+	}
+	if len(specs) == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	bounds := defaultBuckets
+	if buckets != "" {
+		b, err := parseBuckets(buckets)
+		if err != nil {
+			log.Fatal(err)
+		}
+		bounds = b
+	}
+
+	var qs []float64
+	if quantiles != "" {
+		q, err := parseQuantiles(quantiles)
+		if err != nil {
+			log.Fatal(err)
+		}
+		qs = q
+	}
+
+	voiceCodec, err := monitor.CodecByName(codec)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Bind to sockets
+	pinger, err := ping.New(bind4, bind6)
+	if err != nil {
+		fmt.Printf("Unable to bind: %s\nRunning as root?\n", err)
+		os.Exit(2)
+	}
+	pinger.SetPayloadSize(uint16(size))
+	pinger.Reopen = true
+
+	if rebind {
+		if _, err := pinger.WatchAddresses(context.Background()); err != nil {
+			log.Fatalf("unable to watch addresses: %v", err)
+		}
+	}
+
+	// Create monitor
+	mon := monitor.New(pinger, pingInterval, pingTimeout)
+	mon.HistorySize = historySize
+	mon.Quantiles = qs
+	mon.Codec = &voiceCodec
+	defer mon.Stop()
+
+	coll := newCollector(mon, bounds)
+	mon.OnResult = coll.observe
+
+	// Add targets
+	for i, spec := range specs {
+		ipAddr, err := net.ResolveIPAddr("", spec.host)
+		if err != nil {
+			log.Printf("invalid target '%s': %s", spec.host, err)
+			continue
+		}
+		if err := mon.AddTargetDelayed(spec.host, *ipAddr, 10*time.Millisecond*time.Duration(i)); err != nil {
+			log.Printf("unable to add target '%s': %s", spec.host, err)
+			continue
+		}
+		coll.addTarget(spec.host, *ipAddr, spec.labels)
+	}
+
+	// Start HTTP server
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", coll)
+	server := &http.Server{Addr: listen, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+	defer server.Close()
+
+	// Handle SIGINT and SIGTERM.
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+	fmt.Println("received", <-ch)
+}
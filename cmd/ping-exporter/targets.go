@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// targetSpec describes a single target, as given on the command line
+// or in a target file.
+type targetSpec struct {
+	host   string
+	labels []label
+}
+
+var labelNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// readTargets reads targets from the file at path.
+func readTargets(path string) ([]targetSpec, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseTargets(f)
+}
+
+// parseTargets parses a target list. Each line contains a host name
+// or address, optionally followed by whitespace separated labels in the
+// form name=value. Empty lines and lines starting with '#' are ignored:
+//
+//	# core routers
+//	192.0.2.1 site=fra role=core
+//	example.com site=ber
+func parseTargets(r io.Reader) ([]targetSpec, error) {
+	var specs []targetSpec
+
+	scanner := bufio.NewScanner(r)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		spec := targetSpec{host: fields[0]}
+		seen := make(map[string]bool)
+		for _, field := range fields[1:] {
+			l, err := parseLabel(field)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineno, err)
+			}
+			if seen[l.name] {
+				return nil, fmt.Errorf("line %d: duplicate label %q", lineno, l.name)
+			}
+			seen[l.name] = true
+			spec.labels = append(spec.labels, l)
+		}
+		specs = append(specs, spec)
+	}
+
+	return specs, scanner.Err()
+}
+
+// parseLabel parses a single name=value pair.
+func parseLabel(s string) (label, error) {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return label{}, fmt.Errorf("invalid label %q, expected name=value", s)
+	}
+	if !labelNameRe.MatchString(name) || strings.HasPrefix(name, "__") {
+		return label{}, fmt.Errorf("invalid label name %q", name)
+	}
+	switch name {
+	case "target", "address", "le", "quantile":
+		return label{}, fmt.Errorf("label name %q is reserved", name)
+	}
+	return label{name: name, value: value}, nil
+}
@@ -1,37 +1,91 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"net"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/digineo/go-ping"
 	"github.com/digineo/go-ping/monitor"
+	"github.com/digineo/go-ping/monitor/alert"
 )
 
 var (
 	pingInterval        = 5 * time.Second
 	pingTimeout         = 4 * time.Second
 	reportInterval      = 60 * time.Second
+	historySize         = 10
 	size           uint = 56
+	bind4               = "0.0.0.0"
+	bind6               = "::"
+	rebind         bool
+	dnsTTL         bool
+	repeatInterval = time.Hour
+	alerts         ruleFlags
+	webhook        string
+	useSyslog      bool
+	smtpAddr       string
+	mailFrom       string
+	mailTo         string
+	stateFile      string
+	saveInterval   = time.Minute
+	resultLog      string
+	rotateSize     int64
+	rotateAge      time.Duration
 	pinger         *ping.Pinger
 	targets        []string
 )
 
+// ruleFlags collects the -alert flags.
+type ruleFlags []*alert.Rule
+
+func (f *ruleFlags) String() string {
+	return fmt.Sprint(*f)
+}
+
+func (f *ruleFlags) Set(expr string) error {
+	rule, err := alert.ParseRule(expr, expr)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, rule)
+	return nil
+}
+
 func main() {
 	flag.Usage = func() {
 		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[options] host [host [...]]")
+		fmt.Fprintln(os.Stderr, "Hosts may be given as icmp://host, tcp://host:port or udp://host:port.")
 		flag.PrintDefaults()
 	}
 
 	flag.DurationVar(&pingInterval, "pingInterval", pingInterval, "interval for ICMP echo requests")
 	flag.DurationVar(&pingTimeout, "pingTimeout", pingTimeout, "timeout for ICMP echo request")
 	flag.DurationVar(&reportInterval, "reportInterval", reportInterval, "interval for reports")
+	flag.IntVar(&historySize, "historySize", historySize, "number of results per target kept for reports and alert rules")
 	flag.UintVar(&size, "size", size, "size of additional payload data")
+	flag.StringVar(&bind4, "bind4", bind4, "IPv4 bind address")
+	flag.StringVar(&bind6, "bind6", bind6, "IPv6 bind address")
+	flag.BoolVar(&rebind, "rebind", rebind, "rebind when the bind addresses change (Linux only)")
+	flag.BoolVar(&dnsTTL, "dnsTTL", dnsTTL, "re-resolve host names at the TTL of their DNS records")
+	flag.Var(&alerts, "alert", `alert rule, e.g. "loss > 5% for 2m" or "median > 80ms" (repeatable)`)
+	flag.DurationVar(&repeatInterval, "repeatInterval", repeatInterval, "interval for repeated notifications of firing alerts")
+	flag.StringVar(&webhook, "webhook", "", "URL to post alerts to")
+	flag.BoolVar(&useSyslog, "syslog", false, "send alerts to the local syslog")
+	flag.StringVar(&smtpAddr, "smtp", "", "mail server (host:port) to send alerts through")
+	flag.StringVar(&mailFrom, "mailFrom", "ping-monitor@localhost", "sender address of alert mails")
+	flag.StringVar(&mailTo, "mailTo", "", "comma separated recipients of alert mails")
+	flag.StringVar(&stateFile, "stateFile", "", "file to keep the histories in across restarts")
+	flag.DurationVar(&saveInterval, "saveInterval", saveInterval, "interval for saving the state file")
+	flag.StringVar(&resultLog, "resultLog", "", "file to log every result to (CSV if ending in .csv, JSON Lines otherwise)")
+	flag.Int64Var(&rotateSize, "rotateSize", 0, "rotate the result log when exceeding this size in bytes")
+	flag.DurationVar(&rotateAge, "rotateAge", 0, "rotate the result log after this duration")
 	flag.Parse()
 
 	if n := flag.NArg(); n == 0 {
@@ -45,28 +99,121 @@ func main() {
 	}
 
 	// Bind to sockets
-	if p, err := ping.New("0.0.0.0", "::"); err != nil {
+	if p, err := ping.New(bind4, bind6); err != nil {
 		fmt.Printf("Unable to bind: %s\nRunning as root?\n", err)
 		os.Exit(2)
 	} else {
 		pinger = p
 	}
 	pinger.SetPayloadSize(uint16(size))
+	pinger.Reopen = true
 	defer pinger.Close()
 
+	if rebind {
+		if _, err := pinger.WatchAddresses(context.Background()); err != nil {
+			fmt.Printf("Unable to watch addresses: %s\n", err)
+			os.Exit(2)
+		}
+	}
+
+	// Open result log
+	var results *monitor.ResultLog
+	if resultLog != "" {
+		format := monitor.LogJSON
+		if strings.HasSuffix(resultLog, ".csv") {
+			format = monitor.LogCSV
+		}
+		l, err := monitor.NewResultLog(resultLog, format)
+		if err != nil {
+			fmt.Printf("Unable to open result log: %s\n", err)
+			os.Exit(2)
+		}
+		l.MaxSize = rotateSize
+		l.MaxAge = rotateAge
+		l.Compress = true
+		defer l.Close()
+		results = l
+	}
+
+	var resolver ping.Resolver
+	if dnsTTL {
+		resolver = &monitor.DNSResolver{}
+	}
+
 	// Create monitor
 	monitor := monitor.New(pinger, pingInterval, pingTimeout)
 	defer monitor.Stop()
+	monitor.HistorySize = historySize
+	monitor.ResultLog = results
+	monitor.Resolver = resolver
+
+	if stateFile != "" {
+		if err := monitor.Persist(stateFile, saveInterval); err != nil {
+			fmt.Printf("Unable to restore state: %s\n", err)
+			os.Exit(2)
+		}
+	}
+
+	// Print state transitions
+	events, _ := monitor.Subscribe()
+	go func() {
+		for ev := range events {
+			fmt.Printf("%s: %s -> %s\n", targetName(monitor, ev.Key), ev.From, ev.To)
+		}
+	}()
 
 	// Add targets
 	targets = flag.Args()
 	for i, target := range targets {
-		ipAddr, err := net.ResolveIPAddr("", target)
-		if err != nil {
-			fmt.Printf("invalid target '%s': %s", target, err)
+		key := string([]byte{byte(i)})
+		delay := 10 * time.Millisecond * time.Duration(i)
+
+		if strings.Contains(target, "://") {
+			if err := monitor.AddTargetURL(key, target, delay); err != nil {
+				fmt.Printf("invalid target '%s': %s\n", target, err)
+			}
 			continue
 		}
-		monitor.AddTargetDelayed(string([]byte{byte(i)}), *ipAddr, 10*time.Millisecond*time.Duration(i))
+
+		if ip := net.ParseIP(target); ip != nil {
+			monitor.AddTargetDelayed(key, net.IPAddr{IP: ip}, delay)
+			continue
+		}
+
+		// host names are re-resolved periodically
+		if err := monitor.AddHost(key, target); err != nil {
+			fmt.Printf("invalid target '%s': %s\n", target, err)
+		}
+	}
+
+	// Evaluate alert rules
+	if len(alerts) > 0 {
+		evaluator := alert.NewEvaluator(monitor, alerts...)
+		evaluator.RepeatInterval = repeatInterval
+		evaluator.Labels = monitor.Labels
+		if webhook != "" {
+			evaluator.Notifiers = append(evaluator.Notifiers, &alert.WebhookNotifier{URL: webhook})
+		}
+		if useSyslog {
+			n, err := alert.NewSyslogNotifier("", "", "ping-monitor")
+			if err != nil {
+				fmt.Printf("Unable to connect to syslog: %s\n", err)
+				os.Exit(2)
+			}
+			defer n.Close()
+			evaluator.Notifiers = append(evaluator.Notifiers, n)
+		}
+		if smtpAddr != "" && mailTo != "" {
+			evaluator.Notifiers = append(evaluator.Notifiers, &alert.SMTPNotifier{
+				Addr: smtpAddr,
+				From: mailFrom,
+				To:   strings.Split(mailTo, ","),
+			})
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go evaluator.Run(ctx, pingInterval)
 	}
 
 	// Start report routine
@@ -74,8 +221,14 @@ func main() {
 	defer ticker.Stop()
 	go func() {
 		for range ticker.C {
-			for i, metrics := range monitor.ExportAndClear() {
-				fmt.Printf("%s: %+v\n", targets[[]byte(i)[0]], *metrics)
+			// the histories are kept for the alert rules
+			for key, metrics := range monitor.ExportWindow(reportInterval) {
+				fmt.Printf("%s: %+v\n", targetName(monitor, key), *metrics)
+			}
+			for key, status := range monitor.Hosts() {
+				if status.Err != nil {
+					fmt.Printf("%s: %v\n", targets[key[0]], status.Err)
+				}
 			}
 		}
 	}()
@@ -85,3 +238,12 @@ func main() {
 	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
 	fmt.Println("received", <-ch)
 }
+
+// targetName returns the command line argument of a target key, followed
+// by the address for host targets.
+func targetName(m *monitor.Monitor, key string) string {
+	if host, addr, ok := m.HostOf(key); ok {
+		return targets[host[0]] + " (" + addr.String() + ")"
+	}
+	return targets[key[0]]
+}
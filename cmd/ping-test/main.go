@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -18,6 +19,8 @@ var (
 	proto4, proto6 bool
 	size           uint = 56
 	bind           string
+	recordRoute    bool
+	timestamp      string
 
 	destination string
 	remoteAddr  *net.IPAddr
@@ -36,6 +39,8 @@ func main() {
 	flag.BoolVar(&proto4, "4", proto4, "use IPv4 (mutually exclusive with -6)")
 	flag.BoolVar(&proto6, "6", proto6, "use IPv6 (mutually exclusive with -4)")
 	flag.StringVar(&bind, "bind", "", "IPv4 or IPv6 bind address (defaults to 0.0.0.0 for IPv4 and :: for IPv6)")
+	flag.BoolVar(&recordRoute, "R", recordRoute, "record route (IPv4 only)")
+	flag.StringVar(&timestamp, "T", timestamp, "record timestamps, either tsonly or tsandaddr (IPv4 only)")
 	flag.Parse()
 
 	if proto4 == proto6 {
@@ -84,7 +89,9 @@ func main() {
 		pinger.SetPayloadSize(uint16(size))
 	}
 
-	if remoteAddr.IP.IsLinkLocalMulticast() {
+	if recordRoute || timestamp != "" {
+		optionsPing()
+	} else if remoteAddr.IP.IsLinkLocalMulticast() {
 		multicastPing()
 	} else {
 		unicastPing()
@@ -116,3 +123,41 @@ func multicastPing() {
 		fmt.Printf("%+v\n", response)
 	}
 }
+
+func optionsPing() {
+	var opts ping.IPOptions
+	opts.RecordRoute = recordRoute
+	switch timestamp {
+	case "":
+	case "tsonly":
+		opts.Timestamp = ping.TimestampOnly
+	case "tsandaddr":
+		opts.Timestamp = ping.TimestampAndAddress
+	default:
+		log.Fatalf("invalid timestamp option %q", timestamp)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	reply, err := pinger.PingOptions(ctx, remoteAddr, opts)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("ping %s (%s) rtt=%v\n", destination, remoteAddr, reply.RTT)
+	for _, hop := range reply.Route {
+		fmt.Printf("\t%s\n", hop)
+	}
+	for _, ts := range reply.Timestamps {
+		if ts.Addr != nil {
+			fmt.Printf("\t%s\t%s\n", ts.Addr, ts.Time.Format("15:04:05.000"))
+		} else {
+			fmt.Printf("\t%s\n", ts.Time.Format("15:04:05.000"))
+		}
+	}
+	if reply.Overflow > 0 {
+		fmt.Printf("\t(%d hops unable to record a timestamp)\n", reply.Overflow)
+	}
+}
@@ -1,10 +1,18 @@
 package ping
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"golang.org/x/net/icmp"
+)
 
 var (
-	errClosed   = errors.New("pinger closed")
-	errNotBound = errors.New("need at least one bind address")
+	errClosed        = fmt.Errorf("pinger closed: %w", net.ErrClosed)
+	errNotBound      = errors.New("need at least one bind address")
+	errUnsupported   = errors.New("message type not supported for this address family")
+	errSequenceInUse = errors.New("sequence number still in use")
 )
 
 // timeoutError implements the net.Error interface. Originally taken from
@@ -14,3 +22,91 @@ type timeoutError struct{}
 func (e *timeoutError) Error() string   { return "i/o timeout" }
 func (e *timeoutError) Timeout() bool   { return true }
 func (e *timeoutError) Temporary() bool { return true }
+
+// DestinationUnreachableError is returned when an ICMP Destination
+// Unreachable message was received in response to an echo request.
+type DestinationUnreachableError struct {
+	Type       icmp.Type  // ICMP message type
+	Code       int        // ICMP code, e.g. 1 for host unreachable
+	Source     net.IP     // sender of the ICMP message
+	Extensions Extensions // ICMP extension objects, if any
+}
+
+func (e *DestinationUnreachableError) Error() string { return fmt.Sprint(e.Type) }
+
+// TimeExceededError is returned when an ICMP Time Exceeded message was
+// received in response to an echo request, i.e. the TTL (or hop limit)
+// was too small to reach the destination.
+type TimeExceededError struct {
+	Type       icmp.Type  // ICMP message type
+	Code       int        // ICMP code, 0 for TTL exceeded in transit
+	Source     net.IP     // sender of the ICMP message
+	Extensions Extensions // ICMP extension objects, if any
+}
+
+func (e *TimeExceededError) Error() string { return fmt.Sprint(e.Type) }
+
+// Roles of RFC 5837 Interface Information Objects.
+const (
+	InterfaceRoleIncoming = 0 // interface the datagram arrived on
+	InterfaceRoleSubIP    = 1 // sub-IP component of the incoming interface
+	InterfaceRoleOutgoing = 2 // interface the datagram would have been sent on
+	InterfaceRoleNextHop  = 3 // IP next hop the datagram would have been sent to
+)
+
+// Extensions are the ICMP extension objects (RFC 4884) attached to an
+// ICMP error message. Routers commonly add MPLS label stacks (RFC 4950)
+// and interface information (RFC 5837).
+type Extensions []icmp.Extension
+
+// MPLSLabels returns the labels of all MPLS label stack objects, top of
+// stack first.
+func (ext Extensions) MPLSLabels() []icmp.MPLSLabel {
+	var labels []icmp.MPLSLabel
+	for _, e := range ext {
+		if ls, ok := e.(*icmp.MPLSLabelStack); ok && ls != nil {
+			labels = append(labels, ls.Labels...)
+		}
+	}
+	return labels
+}
+
+// Interfaces returns all interface information objects.
+func (ext Extensions) Interfaces() []*icmp.InterfaceInfo {
+	var infos []*icmp.InterfaceInfo
+	for _, e := range ext {
+		if ifi, ok := e.(*icmp.InterfaceInfo); ok && ifi != nil {
+			infos = append(infos, ifi)
+		}
+	}
+	return infos
+}
+
+// Interface returns the first interface information object with the
+// given role (see InterfaceRoleIncoming etc.), or nil.
+func (ext Extensions) Interface(role int) *icmp.InterfaceInfo {
+	for _, ifi := range ext.Interfaces() {
+		if ifi.Type>>6 == role {
+			return ifi
+		}
+	}
+	return nil
+}
+
+// SourceMismatchError describes a reply from another address than the
+// destination of the request, or an ICMP error message quoting a request
+// sent to another destination. This indicates a spoofed or misrouted
+// reply, or an anycast destination (see Pinger.AcceptAnySource). Such
+// replies are logged at debug level and otherwise ignored.
+type SourceMismatchError struct {
+	Destination net.IP // destination of the request
+	Source      net.IP // source of the reply, or destination of the quoted request
+	Err         error  // ICMP error quoting the request, nil for replies
+}
+
+func (e *SourceMismatchError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%v quoting request to %s instead of %s", e.Err, e.Source, e.Destination)
+	}
+	return fmt.Sprintf("reply from %s instead of %s", e.Source, e.Destination)
+}
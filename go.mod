@@ -3,11 +3,11 @@ module github.com/digineo/go-ping
 go 1.23.0
 
 require (
-	github.com/digineo/go-logwrap v0.0.0-20181106161722-a178c58ea3f0
 	github.com/gdamore/tcell/v2 v2.9.0
 	github.com/rivo/tview v0.0.0-20250625164341-a4a78f1e05cb
 	github.com/stretchr/testify v1.11.0
 	golang.org/x/net v0.43.0
+	golang.org/x/sys v0.35.0
 	gopkg.in/cheggaaa/pb.v1 v1.0.28
 )
 
@@ -21,7 +21,6 @@ require (
 	github.com/mattn/go-runewidth v0.0.16 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
-	golang.org/x/sys v0.35.0 // indirect
 	golang.org/x/term v0.34.0 // indirect
 	golang.org/x/text v0.28.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
@@ -0,0 +1,184 @@
+package ping
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// A Resolver looks up the addresses of a host. *net.Resolver implements
+// this interface.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// HostStrategy selects the addresses pinged by PingHost.
+type HostStrategy int
+
+const (
+	FirstAddress  HostStrategy = iota // ping the first address of the preferred family
+	RaceAddresses                     // race all addresses Happy Eyeballs style, the first reply wins
+	AllAddresses                      // ping all addresses concurrently
+)
+
+// DefaultRaceDelay is the default head start of an address before the
+// next one is pinged, when racing addresses (see RFC 8305, section 5).
+const DefaultRaceDelay = 250 * time.Millisecond
+
+// HostOptions configure PingHost.
+type HostOptions struct {
+	Resolver   Resolver      // defaults to net.DefaultResolver
+	Strategy   HostStrategy  // defaults to FirstAddress
+	RaceDelay  time.Duration // defaults to DefaultRaceDelay
+	PreferIPv4 bool          // try IPv4 before IPv6 addresses
+}
+
+// HostReply is the outcome of pinging a single address of a host.
+type HostReply struct {
+	Addr net.IPAddr    // pinged address
+	RTT  time.Duration // round trip time, if successful
+	Err  error         // reason of failure, nil on success
+}
+
+// PingHost resolves the given host name and pings its addresses
+// according to opts.Strategy. Addresses of a family the Pinger has no
+// socket for are skipped.
+//
+// The replies contain the addresses which answered, together with those
+// that failed before. The returned error is nil if at least one address
+// answered.
+func (pinger *Pinger) PingHost(ctx context.Context, host string, opts HostOptions) ([]HostReply, error) {
+	resolver := opts.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	addrs = pinger.sortAddrs(addrs, opts.PreferIPv4)
+	if len(addrs) == 0 {
+		return nil, errors.New("no usable address for " + host)
+	}
+
+	switch opts.Strategy {
+	case RaceAddresses:
+		delay := opts.RaceDelay
+		if delay <= 0 {
+			delay = DefaultRaceDelay
+		}
+		return pinger.raceAddrs(ctx, addrs, delay)
+	case AllAddresses:
+		return pinger.pingAddrs(ctx, addrs)
+	default:
+		return pinger.pingAddrs(ctx, addrs[:1])
+	}
+}
+
+// sortAddrs removes the addresses we cannot ping and interleaves the
+// address families, starting with the preferred one.
+func (pinger *Pinger) sortAddrs(addrs []net.IPAddr, preferIPv4 bool) []net.IPAddr {
+	pinger.connMtx.RLock()
+	has4, has6 := pinger.conn4 != nil, pinger.conn6 != nil
+	pinger.connMtx.RUnlock()
+
+	var v4, v6 []net.IPAddr
+	for _, addr := range addrs {
+		if addr.IP.To4() != nil {
+			if has4 {
+				v4 = append(v4, addr)
+			}
+		} else if has6 {
+			v6 = append(v6, addr)
+		}
+	}
+
+	first, second := v6, v4
+	if preferIPv4 {
+		first, second = v4, v6
+	}
+
+	sorted := make([]net.IPAddr, 0, len(v4)+len(v6))
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			sorted = append(sorted, first[i])
+		}
+		if i < len(second) {
+			sorted = append(sorted, second[i])
+		}
+	}
+	return sorted
+}
+
+// pingAddrs pings all addresses concurrently.
+func (pinger *Pinger) pingAddrs(ctx context.Context, addrs []net.IPAddr) ([]HostReply, error) {
+	replies := make([]HostReply, len(addrs))
+	done := make(chan struct{})
+
+	for i := range addrs {
+		replies[i].Addr = addrs[i]
+		go func(reply *HostReply) {
+			reply.RTT, reply.Err = pinger.PingContext(ctx, &reply.Addr)
+			done <- struct{}{}
+		}(&replies[i])
+	}
+	for range addrs {
+		<-done
+	}
+
+	err := replies[0].Err
+	for _, reply := range replies {
+		if reply.Err == nil {
+			return replies, nil
+		}
+	}
+	return replies, err
+}
+
+// raceAddrs pings the addresses one after another, each delay apart or
+// as soon as the previous one failed, until the first reply arrives.
+func (pinger *Pinger) raceAddrs(ctx context.Context, addrs []net.IPAddr, delay time.Duration) ([]HostReply, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel() // stop the losers
+
+	results := make(chan HostReply, len(addrs))
+	next, pending := 0, 0
+	start := func() {
+		reply := HostReply{Addr: addrs[next]}
+		next++
+		pending++
+		go func() {
+			reply.RTT, reply.Err = pinger.PingContext(ctx, &reply.Addr)
+			results <- reply
+		}()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var failed []HostReply
+	for start(); pending > 0; {
+		select {
+		case <-timer.C:
+			if next < len(addrs) && ctx.Err() == nil {
+				start()
+				timer.Reset(delay)
+			}
+		case reply := <-results:
+			pending--
+			if reply.Err == nil {
+				return append(failed, reply), nil
+			}
+			failed = append(failed, reply)
+
+			if next < len(addrs) && ctx.Err() == nil {
+				start()
+				timer.Reset(delay)
+			}
+		}
+	}
+
+	return failed, failed[0].Err
+}
@@ -0,0 +1,93 @@
+package ping
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// Logger is the interface of the loggers formerly used by this package
+// (see github.com/digineo/go-logwrap).
+//
+// Deprecated: Use Pinger.Logger with a log/slog logger instead.
+type Logger interface {
+	Infof(format string, a ...interface{})
+	Errorf(format string, a ...interface{})
+}
+
+// defaultLogger is set by SetLogger.
+var defaultLogger atomic.Pointer[slog.Logger]
+
+// SetLogger sets the logger of Pingers without a Logger. Records of level
+// Error and above are passed to l.Errorf, Info and Warn to l.Infof, and
+// Debug records are dropped. A nil l restores slog.Default().
+//
+// Deprecated: Set Pinger.Logger instead.
+func SetLogger(l Logger) {
+	if l == nil {
+		defaultLogger.Store(nil)
+		return
+	}
+	defaultLogger.Store(slog.New(newLegacyHandler(l)))
+}
+
+// legacyHandler is a slog.Handler formatting records as text for a Logger.
+type legacyHandler struct {
+	logger Logger
+	text   slog.Handler  // writes to buf
+	buf    *bytes.Buffer // shared by all derived handlers
+	mtx    *sync.Mutex   // lock for buf
+}
+
+func newLegacyHandler(l Logger) *legacyHandler {
+	buf := &bytes.Buffer{}
+	return &legacyHandler{
+		logger: l,
+		text: slog.NewTextHandler(buf, &slog.HandlerOptions{
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey) {
+					return slog.Attr{} // added by the Logger
+				}
+				return a
+			},
+		}),
+		buf: buf,
+		mtx: &sync.Mutex{},
+	}
+}
+
+func (h *legacyHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= slog.LevelInfo
+}
+
+func (h *legacyHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.mtx.Lock()
+	h.buf.Reset()
+	err := h.text.Handle(ctx, r)
+	msg := string(bytes.TrimSuffix(h.buf.Bytes(), []byte("\n")))
+	h.mtx.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if r.Level >= slog.LevelError {
+		h.logger.Errorf("%s", msg)
+	} else {
+		h.logger.Infof("%s", msg)
+	}
+	return nil
+}
+
+func (h *legacyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.text = h.text.WithAttrs(attrs)
+	return &h2
+}
+
+func (h *legacyHandler) WithGroup(name string) slog.Handler {
+	h2 := *h
+	h2.text = h.text.WithGroup(name)
+	return &h2
+}
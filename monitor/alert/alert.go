@@ -0,0 +1,258 @@
+// Package alert evaluates threshold rules on the metrics of a
+// monitor.Monitor and sends notifications when alerts fire and resolve.
+package alert
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/digineo/go-ping/monitor"
+)
+
+// State is the lifecycle state of an alert.
+type State int
+
+// An alert is pending while the threshold is exceeded for less than the
+// rule's For duration, then firing until the threshold is no longer
+// exceeded, and finally resolved.
+const (
+	StatePending State = iota
+	StateFiring
+	StateResolved
+)
+
+func (s State) String() string {
+	switch s {
+	case StatePending:
+		return "pending"
+	case StateFiring:
+		return "firing"
+	default:
+		return "resolved"
+	}
+}
+
+// Alert is a rule exceeded by a target.
+type Alert struct {
+	Rule       *Rule
+	Key        string            // target key
+	Labels     map[string]string // target labels
+	State      State
+	Value      float64   // last evaluated value of the metric
+	Partial    bool      // whether the value covers only part of the rule's window
+	ActiveAt   time.Time // when the threshold was first exceeded
+	FiredAt    time.Time // zero while pending
+	ResolvedAt time.Time // zero unless resolved
+
+	notified time.Time // last notification while firing
+}
+
+func (a *Alert) String() string {
+	s := fmt.Sprintf("[%s] %s: %s on %s (value %.4g", a.State, a.Rule.Name, a.Rule, a.Key, a.Value)
+	if a.Partial {
+		s += ", partial window"
+	}
+	return s + ")"
+}
+
+// A Notifier delivers alerts.
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// Source provides the metrics of the targets. It is implemented by
+// *monitor.Monitor.
+type Source interface {
+	Export() map[string]*monitor.Metrics
+	ExportWindow(time.Duration) map[string]*monitor.Metrics
+	Targets() []string // keys of the monitored targets
+}
+
+type alertKey struct {
+	rule *Rule
+	key  string
+}
+
+// Evaluator checks the rules against the metrics of a Source.
+type Evaluator struct {
+	Rules     []*Rule
+	Notifiers []Notifier
+
+	// RepeatInterval is the interval at which firing alerts are notified
+	// again. Zero notifies only once.
+	RepeatInterval time.Duration
+
+	// Labels returns the labels of a target, if set, e.g.
+	// monitor.Monitor.Labels.
+	Labels func(key string) map[string]string
+
+	// NotifyTimeout limits the delivery by each notifier. Defaults to 10s.
+	NotifyTimeout time.Duration
+
+	// Logger receives failed notifications. Defaults to slog.Default().
+	Logger *slog.Logger
+
+	source Source
+	alerts map[alertKey]*Alert
+	mtx    sync.Mutex
+}
+
+const defaultNotifyTimeout = 10 * time.Second
+
+// NewEvaluator creates an Evaluator for the given rules.
+func NewEvaluator(source Source, rules ...*Rule) *Evaluator {
+	return &Evaluator{
+		Rules:         rules,
+		NotifyTimeout: defaultNotifyTimeout,
+		source:        source,
+		alerts:        make(map[alertKey]*Alert),
+	}
+}
+
+// Run evaluates the rules at the given interval until ctx is done.
+func (e *Evaluator) Run(ctx context.Context, interval time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-tick.C:
+			e.Evaluate(ctx, now)
+		}
+	}
+}
+
+// Alerts returns the pending and firing alerts, ordered by rule and key.
+func (e *Evaluator) Alerts() []Alert {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	alerts := make([]Alert, 0, len(e.alerts))
+	for _, a := range e.alerts {
+		alerts = append(alerts, *a)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Rule.Name != alerts[j].Rule.Name {
+			return alerts[i].Rule.Name < alerts[j].Rule.Name
+		}
+		return alerts[i].Key < alerts[j].Key
+	})
+	return alerts
+}
+
+// Evaluate checks all rules once and sends the notifications due. An
+// alert is notified when it fires, every RepeatInterval while firing, and
+// when it resolves. Alerts resolve when the metric is defined and no
+// longer exceeds the threshold, or when the target is removed. Targets
+// without metrics (e.g. after Monitor.ExportAndClear) or with undefined
+// values keep their alerts unchanged.
+func (e *Evaluator) Evaluate(ctx context.Context, now time.Time) {
+	var notify []Alert
+
+	e.mtx.Lock()
+	windows := make(map[time.Duration]map[string]*monitor.Metrics)
+	keep := make(map[alertKey]bool)
+	targets := make(map[string]bool)
+	for _, key := range e.source.Targets() {
+		targets[key] = true
+	}
+
+	for _, rule := range e.Rules {
+		exported, ok := windows[rule.Window]
+		if !ok {
+			if rule.Window > 0 {
+				exported = e.source.ExportWindow(rule.Window)
+			} else {
+				exported = e.source.Export()
+			}
+			windows[rule.Window] = exported
+		}
+
+		for key, m := range exported {
+			var labels map[string]string
+			if e.Labels != nil {
+				labels = e.Labels(key)
+			}
+			if !rule.matches(key, labels) {
+				continue
+			}
+
+			ak := alertKey{rule, key}
+			value, exceeded := rule.value(m)
+			if math.IsNaN(value) {
+				keep[ak] = true
+				continue
+			}
+			if !exceeded {
+				continue
+			}
+
+			keep[ak] = true
+			a := e.alerts[ak]
+			if a == nil {
+				a = &Alert{Rule: rule, Key: key, Labels: labels, ActiveAt: now}
+				e.alerts[ak] = a
+			}
+			a.Value = value
+			a.Partial = m.Partial
+
+			switch {
+			case a.State == StatePending && now.Sub(a.ActiveAt) >= rule.For:
+				a.State = StateFiring
+				a.FiredAt = now
+				a.notified = now
+				notify = append(notify, *a)
+			case a.State == StateFiring && e.RepeatInterval > 0 && now.Sub(a.notified) >= e.RepeatInterval:
+				a.notified = now
+				notify = append(notify, *a)
+			}
+		}
+	}
+
+	for ak, a := range e.alerts {
+		if _, exported := windows[ak.rule.Window][ak.key]; keep[ak] || (!exported && targets[ak.key]) {
+			continue
+		}
+		delete(e.alerts, ak)
+		if a.State == StateFiring {
+			a.State = StateResolved
+			a.ResolvedAt = now
+			notify = append(notify, *a)
+		}
+	}
+	e.mtx.Unlock()
+
+	for _, a := range notify {
+		e.notify(ctx, a)
+	}
+}
+
+// notify passes the alert to all notifiers.
+func (e *Evaluator) notify(ctx context.Context, a Alert) {
+	timeout := e.NotifyTimeout
+	if timeout <= 0 {
+		timeout = defaultNotifyTimeout
+	}
+	for _, n := range e.Notifiers {
+		nctx, cancel := context.WithTimeout(ctx, timeout)
+		err := n.Notify(nctx, a)
+		cancel()
+		if err != nil {
+			e.logger().Warn("notification failed", "alert", a.Rule.Name, "target", a.Key, "error", err)
+		}
+	}
+}
+
+func (e *Evaluator) logger() *slog.Logger {
+	if e.Logger != nil {
+		return e.Logger
+	}
+	return slog.Default()
+}
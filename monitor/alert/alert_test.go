@@ -0,0 +1,321 @@
+package alert
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/digineo/go-ping/monitor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testSource struct {
+	metrics map[string]*monitor.Metrics
+	targets []string
+}
+
+func (s *testSource) Export() map[string]*monitor.Metrics                    { return s.metrics }
+func (s *testSource) ExportWindow(time.Duration) map[string]*monitor.Metrics { return s.metrics }
+func (s *testSource) Targets() []string                                      { return s.targets }
+
+type testNotifier struct {
+	alerts []Alert
+	err    error // of the last context
+}
+
+func (n *testNotifier) Notify(ctx context.Context, a Alert) error {
+	n.alerts = append(n.alerts, a)
+	n.err = ctx.Err()
+	return nil
+}
+
+func TestParseRule(t *testing.T) {
+	assert := assert.New(t)
+
+	for expr, expected := range map[string]Rule{
+		"loss > 5% for 2m":         {Metric: "loss", Threshold: 5, For: 2 * time.Minute},
+		"median > 80ms":            {Metric: "median", Threshold: 80},
+		"jitter > 0.5s over 10m":   {Metric: "jitter", Threshold: 500, Window: 10 * time.Minute},
+		"mos < 3.6 for 1m over 5m": {Metric: "mos", Below: true, Threshold: 3.6, For: time.Minute, Window: 5 * time.Minute},
+	} {
+		rule, err := ParseRule("test", expr)
+		if assert.NoError(err, expr) {
+			expected.Name = "test"
+			assert.Equal(&expected, rule, expr)
+		}
+	}
+
+	for _, expr := range []string{
+		"loss",
+		"rtt > 5",
+		"loss >= 5",
+		"loss > five",
+		"loss > 5 for",
+		"loss > 5 during 2m",
+		"loss > 5 for two",
+	} {
+		_, err := ParseRule("test", expr)
+		assert.Error(err, expr)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	lossy := &monitor.Metrics{PacketsSent: 10, PacketsLost: 1}
+	metrics := map[string]*monitor.Metrics{"a": lossy, "b": {PacketsSent: 10}}
+	source := &testSource{metrics: metrics, targets: []string{"a", "b"}}
+	rule, err := ParseRule("loss", "loss > 5% for 2m")
+	assert.NoError(err)
+
+	notifier := &testNotifier{}
+	e := NewEvaluator(source, rule)
+	e.Notifiers = []Notifier{notifier}
+	e.RepeatInterval = 5 * time.Minute
+
+	// pending
+	e.Evaluate(ctx, now)
+	alerts := e.Alerts()
+	if assert.Len(alerts, 1) {
+		assert.Equal("a", alerts[0].Key)
+		assert.Equal(StatePending, alerts[0].State)
+		assert.EqualValues(10, alerts[0].Value)
+	}
+	assert.Empty(notifier.alerts)
+
+	// a cleared history keeps the pending timer
+	source.metrics = map[string]*monitor.Metrics{}
+	e.Evaluate(ctx, now.Add(time.Minute))
+	assert.Len(e.Alerts(), 1)
+	source.metrics = metrics
+
+	// firing
+	e.Evaluate(ctx, now.Add(2*time.Minute))
+	e.Evaluate(ctx, now.Add(3*time.Minute))
+	if assert.Len(notifier.alerts, 1) {
+		assert.Equal(StateFiring, notifier.alerts[0].State)
+		assert.Equal(now.Add(2*time.Minute), notifier.alerts[0].FiredAt)
+	}
+
+	// neither cleared histories nor undefined values resolve
+	source.metrics = map[string]*monitor.Metrics{}
+	e.Evaluate(ctx, now.Add(4*time.Minute))
+	source.metrics = map[string]*monitor.Metrics{"a": {}}
+	e.Evaluate(ctx, now.Add(5*time.Minute))
+	source.metrics = metrics
+	e.Evaluate(ctx, now.Add(6*time.Minute))
+	assert.Len(notifier.alerts, 1)
+	if alerts := e.Alerts(); assert.Len(alerts, 1) {
+		assert.Equal(StateFiring, alerts[0].State)
+	}
+
+	// repeated
+	e.Evaluate(ctx, now.Add(7*time.Minute))
+	assert.Len(notifier.alerts, 2)
+
+	// resolved
+	lossy.PacketsLost = 0
+	e.Evaluate(ctx, now.Add(8*time.Minute))
+	if assert.Len(notifier.alerts, 3) {
+		assert.Equal(StateResolved, notifier.alerts[2].State)
+		assert.Equal(now.Add(8*time.Minute), notifier.alerts[2].ResolvedAt)
+	}
+	assert.Empty(e.Alerts())
+
+	// pending alerts resolve silently
+	lossy.PacketsLost = 1
+	e.Evaluate(ctx, now.Add(9*time.Minute))
+	lossy.PacketsLost = 0
+	e.Evaluate(ctx, now.Add(10*time.Minute))
+	assert.Len(notifier.alerts, 3)
+	assert.Empty(e.Alerts())
+
+	// removed targets resolve
+	lossy.PacketsLost = 1
+	e.Evaluate(ctx, now.Add(11*time.Minute))
+	e.Evaluate(ctx, now.Add(13*time.Minute))
+	assert.Len(notifier.alerts, 4)
+	source.metrics = map[string]*monitor.Metrics{}
+	source.targets = []string{"b"}
+	e.Evaluate(ctx, now.Add(14*time.Minute))
+	if assert.Len(notifier.alerts, 5) {
+		assert.Equal(StateResolved, notifier.alerts[4].State)
+	}
+	assert.Empty(e.Alerts())
+}
+
+func TestNotifyTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	source := &testSource{metrics: map[string]*monitor.Metrics{"a": {PacketsSent: 10, PacketsLost: 5}}, targets: []string{"a"}}
+	rule, err := ParseRule("loss", "loss > 5%")
+	assert.NoError(err)
+
+	notifier := &testNotifier{}
+	e := NewEvaluator(source, rule)
+	e.Notifiers = []Notifier{notifier}
+	e.NotifyTimeout = 0
+
+	e.Evaluate(context.Background(), time.Now())
+	assert.Len(notifier.alerts, 1)
+	assert.NoError(notifier.err)
+}
+
+func TestRuleMatches(t *testing.T) {
+	assert := assert.New(t)
+
+	rule := &Rule{Targets: []string{"core-*"}, Labels: map[string]string{"site": "fra"}}
+	assert.True(rule.matches("core-1", map[string]string{"site": "fra", "role": "router"}))
+	assert.False(rule.matches("core-1", map[string]string{"site": "ber"}))
+	assert.False(rule.matches("cpe-1", map[string]string{"site": "fra"}))
+	assert.True((&Rule{}).matches("cpe-1", nil))
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var payload webhookPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("application/json", r.Header.Get("Content-Type"))
+		assert.NoError(json.NewDecoder(r.Body).Decode(&payload))
+	}))
+	defer srv.Close()
+
+	rule, _ := ParseRule("latency", "median > 80ms")
+	now := time.Now().UTC()
+	n := &WebhookNotifier{URL: srv.URL}
+	require.NoError(n.Notify(context.Background(), Alert{Rule: rule, Key: "a", State: StateFiring, Value: 95, ActiveAt: now, FiredAt: now}))
+
+	assert.Equal("latency", payload.Rule)
+	assert.Equal("median > 80", payload.Expr)
+	assert.Equal("firing", payload.State)
+	assert.EqualValues(95, payload.Value)
+	assert.Nil(payload.ResolvedAt)
+
+	n.URL = srv.URL + "/%zz"
+	assert.Error(n.Notify(context.Background(), Alert{Rule: rule}))
+}
+
+func TestSyslogNotifier(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(err)
+	defer conn.Close()
+
+	n, err := NewSyslogNotifier("udp", conn.LocalAddr().String(), "ping-monitor")
+	require.NoError(err)
+	defer n.Close()
+
+	rule, _ := ParseRule("loss", "loss > 5%")
+	require.NoError(n.Notify(context.Background(), Alert{Rule: rule, Key: "a", State: StateFiring, Value: 10}))
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	size, _, err := conn.ReadFrom(buf)
+	require.NoError(err)
+	msg := string(buf[:size])
+	assert.True(strings.HasPrefix(msg, "<28>"), msg) // daemon.warning
+	assert.Contains(msg, "ping-monitor")
+	assert.Contains(msg, "[firing] loss: loss > 5 on a (value 10)")
+}
+
+// smtpServer is a minimal SMTP server accepting a single mail.
+type smtpServer struct {
+	ln   net.Listener
+	rcpt []string
+	data string
+	wg   sync.WaitGroup
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &smtpServer{ln: ln}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+func (s *smtpServer) serve() {
+	defer s.wg.Done()
+
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 localhost ESMTP")
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			s.rcpt = append(s.rcpt, strings.TrimSpace(line[len("RCPT TO:"):]))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			s.data = data.String()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	srv := newSMTPServer(t)
+	defer srv.ln.Close()
+
+	rule, _ := ParseRule("loss", "loss > 5%")
+	n := &SMTPNotifier{
+		Addr: srv.ln.Addr().String(),
+		From: "monitor@example.com",
+		To:   []string{"noc@example.com"},
+	}
+	require.NoError(n.Notify(context.Background(), Alert{Rule: rule, Key: "a", State: StateResolved, Labels: map[string]string{"site": "fra"}}))
+	srv.wg.Wait()
+
+	assert.Equal([]string{"<noc@example.com>"}, srv.rcpt)
+	assert.Contains(srv.data, "Subject: [RESOLVED] loss on a\r\n")
+	assert.Contains(srv.data, "site: fra\r\n")
+}
@@ -0,0 +1,103 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// WebhookNotifier posts alerts as JSON to an HTTP endpoint.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client // defaults to http.DefaultClient
+}
+
+// webhookPayload is the JSON body posted by a WebhookNotifier.
+type webhookPayload struct {
+	Rule       string            `json:"rule"`
+	Expr       string            `json:"expr"`
+	Target     string            `json:"target"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	State      string            `json:"state"`
+	Value      float64           `json:"value"`
+	Partial    bool              `json:"partial,omitempty"`
+	ActiveAt   time.Time         `json:"activeAt"`
+	FiredAt    *time.Time        `json:"firedAt,omitempty"`
+	ResolvedAt *time.Time        `json:"resolvedAt,omitempty"`
+}
+
+// Notify implements Notifier.
+func (n *WebhookNotifier) Notify(ctx context.Context, a Alert) error {
+	payload := webhookPayload{
+		Rule:     a.Rule.Name,
+		Expr:     a.Rule.String(),
+		Target:   a.Key,
+		Labels:   a.Labels,
+		State:    a.State.String(),
+		Value:    a.Value,
+		Partial:  a.Partial,
+		ActiveAt: a.ActiveAt,
+	}
+	if !a.FiredAt.IsZero() {
+		payload.FiredAt = &a.FiredAt
+	}
+	if !a.ResolvedAt.IsZero() {
+		payload.ResolvedAt = &a.ResolvedAt
+	}
+
+	body, err := json.Marshal(&payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", res.Status)
+	}
+	return nil
+}
+
+// SMTPNotifier sends alerts by mail.
+type SMTPNotifier struct {
+	Addr string    // host:port of the mail server
+	Auth smtp.Auth // optional
+	From string
+	To   []string
+}
+
+// Notify implements Notifier. The context is not respected, as net/smtp
+// does not support it.
+func (n *SMTPNotifier) Notify(_ context.Context, a Alert) error {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", n.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&msg, "Subject: [%s] %s on %s\r\n", strings.ToUpper(a.State.String()), a.Rule.Name, a.Key)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s\r\n", &a)
+	fmt.Fprintf(&msg, "\r\nactive since: %s\r\n", a.ActiveAt.Format(time.RFC3339))
+	for name, value := range a.Labels {
+		fmt.Fprintf(&msg, "%s: %s\r\n", name, value)
+	}
+
+	return smtp.SendMail(n.Addr, n.Auth, n.From, n.To, []byte(msg.String()))
+}
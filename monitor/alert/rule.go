@@ -0,0 +1,161 @@
+package alert
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/digineo/go-ping/monitor"
+)
+
+// metrics maps the metric names of rules to their values. Loss and
+// availability are percentages, rtts in ms.
+var metrics = map[string]func(*monitor.Metrics) float64{
+	"loss": func(m *monitor.Metrics) float64 {
+		if m.PacketsSent == 0 {
+			return math.NaN()
+		}
+		return 100 * float64(m.PacketsLost) / float64(m.PacketsSent)
+	},
+	"availability": func(m *monitor.Metrics) float64 { return float64(m.Availability) },
+	"best":         func(m *monitor.Metrics) float64 { return float64(m.Best) },
+	"worst":        func(m *monitor.Metrics) float64 { return float64(m.Worst) },
+	"median":       func(m *monitor.Metrics) float64 { return float64(m.Median) },
+	"mean":         func(m *monitor.Metrics) float64 { return float64(m.Mean) },
+	"stddev":       func(m *monitor.Metrics) float64 { return float64(m.StdDev) },
+	"jitter":       func(m *monitor.Metrics) float64 { return float64(m.Jitter) },
+	"ipdv":         func(m *monitor.Metrics) float64 { return float64(m.IPDVMean) },
+	"mos":          func(m *monitor.Metrics) float64 { return float64(m.MOS) },
+}
+
+// Rule is a threshold on a metric of the monitored targets.
+type Rule struct {
+	Name      string
+	Metric    string  // loss or availability (in %), best, worst, median, mean, stddev, jitter or ipdv (in ms), or mos
+	Below     bool    // whether to alert below instead of above the threshold
+	Threshold float64 // in the unit of the metric
+
+	// For is how long the threshold must be exceeded before the alert
+	// fires. Until then, it is pending.
+	For time.Duration
+
+	// Window limits the evaluation to the results sent within the last
+	// Window (see monitor.Monitor.ExportWindow). Zero uses the whole
+	// history. If the history is too small for the window, alerts are
+	// marked as Partial.
+	Window time.Duration
+
+	// Targets restricts the rule to the target keys matching one of the
+	// patterns (see path.Match). Empty matches all targets.
+	Targets []string
+
+	// Labels restricts the rule to targets having all of these labels.
+	Labels map[string]string
+}
+
+// ParseRule parses a rule expression like "loss > 5% for 2m",
+// "median > 80ms" or "mos < 3.6 for 5m over 10m", where "over" sets the
+// Window.
+func ParseRule(name, expr string) (*Rule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) < 3 || len(fields)%2 == 0 {
+		return nil, fmt.Errorf("invalid rule %q", expr)
+	}
+
+	rule := &Rule{Name: name, Metric: fields[0]}
+	if _, ok := metrics[rule.Metric]; !ok {
+		return nil, fmt.Errorf("unknown metric %q", rule.Metric)
+	}
+
+	switch fields[1] {
+	case ">":
+	case "<":
+		rule.Below = true
+	default:
+		return nil, fmt.Errorf("invalid operator %q", fields[1])
+	}
+
+	threshold, err := parseThreshold(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid threshold %q: %w", fields[2], err)
+	}
+	rule.Threshold = threshold
+
+	for i := 3; i < len(fields); i += 2 {
+		d, err := time.ParseDuration(fields[i+1])
+		if err != nil {
+			return nil, err
+		}
+		switch fields[i] {
+		case "for":
+			rule.For = d
+		case "over":
+			rule.Window = d
+		default:
+			return nil, fmt.Errorf("unexpected %q", fields[i])
+		}
+	}
+	return rule, nil
+}
+
+// parseThreshold parses a number, optionally followed by "%" or a
+// duration unit, which is converted to ms.
+func parseThreshold(s string) (float64, error) {
+	if v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64); err == nil {
+		return v, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	return float64(d) / float64(time.Millisecond), nil
+}
+
+func (r *Rule) String() string {
+	op := ">"
+	if r.Below {
+		op = "<"
+	}
+	s := fmt.Sprintf("%s %s %g", r.Metric, op, r.Threshold)
+	if r.For > 0 {
+		s += " for " + r.For.String()
+	}
+	if r.Window > 0 {
+		s += " over " + r.Window.String()
+	}
+	return s
+}
+
+// matches returns whether the rule applies to the target.
+func (r *Rule) matches(key string, labels map[string]string) bool {
+	for name, value := range r.Labels {
+		if labels[name] != value {
+			return false
+		}
+	}
+	if len(r.Targets) == 0 {
+		return true
+	}
+	for _, pattern := range r.Targets {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// value returns the metric value and whether it exceeds the threshold.
+// Undefined values never do.
+func (r *Rule) value(m *monitor.Metrics) (float64, bool) {
+	v := metrics[r.Metric](m)
+	if math.IsNaN(v) {
+		return v, false
+	}
+	if r.Below {
+		return v, v < r.Threshold
+	}
+	return v, v > r.Threshold
+}
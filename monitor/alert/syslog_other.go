@@ -0,0 +1,27 @@
+//go:build windows || plan9
+
+package alert
+
+import (
+	"context"
+	"errors"
+)
+
+// SyslogNotifier writes alerts to syslog. It is not supported on this
+// platform.
+type SyslogNotifier struct{}
+
+// NewSyslogNotifier is not supported on this platform.
+func NewSyslogNotifier(network, raddr, tag string) (*SyslogNotifier, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
+
+// Notify implements Notifier.
+func (n *SyslogNotifier) Notify(context.Context, Alert) error {
+	return errors.New("syslog is not supported on this platform")
+}
+
+// Close is a no-op.
+func (n *SyslogNotifier) Close() error {
+	return nil
+}
@@ -0,0 +1,37 @@
+//go:build !windows && !plan9
+
+package alert
+
+import (
+	"context"
+	"log/syslog"
+)
+
+// SyslogNotifier writes alerts to syslog.
+type SyslogNotifier struct {
+	writer *syslog.Writer
+}
+
+// NewSyslogNotifier connects to the syslog daemon at raddr, or to the
+// local one if network is empty (see syslog.Dial).
+func NewSyslogNotifier(network, raddr, tag string) (*SyslogNotifier, error) {
+	w, err := syslog.Dial(network, raddr, syslog.LOG_WARNING|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogNotifier{writer: w}, nil
+}
+
+// Notify implements Notifier. Firing alerts are logged as warnings,
+// resolved ones as notices.
+func (n *SyslogNotifier) Notify(_ context.Context, a Alert) error {
+	if a.State == StateResolved {
+		return n.writer.Notice(a.String())
+	}
+	return n.writer.Warning(a.String())
+}
+
+// Close closes the connection to the syslog daemon.
+func (n *SyslogNotifier) Close() error {
+	return n.writer.Close()
+}
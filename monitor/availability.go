@@ -0,0 +1,114 @@
+package monitor
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Outage is a run of consecutive losses of at least History.OutageLosses
+// results.
+type Outage struct {
+	Start time.Time // when the first lost request was sent
+	End   time.Time // when the next answered request was sent, zero if ongoing
+	Lost  int       // number of lost requests
+}
+
+// Duration returns the length of the outage. Ongoing outages last until now.
+func (o *Outage) Duration() time.Duration {
+	if o.End.IsZero() {
+		return time.Since(o.Start)
+	}
+	return o.End.Sub(o.Start)
+}
+
+// setAvailability computes the loss burst statistics, the time since the
+// last success and the availability of the results sent at or after since.
+// Loss bursts of at least minOutage results are reported as outages.
+func (m *Metrics) setAvailability(h *History, since time.Time, minOutage int) {
+	if minOutage < 1 {
+		minOutage = 1
+	}
+
+	var burst, burstLosses, outageLosses int
+	var start, lastSuccess time.Time
+	closeBurst := func(end time.Time) {
+		if burst == 0 {
+			return
+		}
+		m.LossBursts++
+		burstLosses += burst
+		if burst > m.LongestBurst {
+			m.LongestBurst = burst
+		}
+		if burst >= minOutage {
+			m.Outages = append(m.Outages, Outage{Start: start, End: end, Lost: burst})
+			outageLosses += burst
+		}
+		burst = 0
+	}
+
+	total := 0
+	h.each(since, func(res *Result) {
+		total++
+		if !res.Lost {
+			closeBurst(res.Sent)
+			lastSuccess = res.Sent
+			return
+		}
+		if burst == 0 {
+			start = res.Sent
+		}
+		burst++
+	})
+	closeBurst(time.Time{})
+
+	m.MeanBurst = float32(math.NaN())
+	if m.LossBursts > 0 {
+		m.MeanBurst = float32(burstLosses) / float32(m.LossBursts)
+	}
+	m.SinceSuccess = -1
+	if !lastSuccess.IsZero() {
+		m.SinceSuccess = time.Since(lastSuccess)
+	}
+	if total > 0 {
+		m.Availability = 100 * float32(total-outageLosses) / float32(total)
+	}
+}
+
+// mergeAvailability combines the loss burst statistics and availability
+// of m and o into merged.
+func mergeAvailability(merged, m, o *Metrics) {
+	merged.LossBursts = m.LossBursts + o.LossBursts
+	merged.LongestBurst = max(m.LongestBurst, o.LongestBurst)
+
+	merged.MeanBurst = float32(math.NaN())
+	if merged.LossBursts > 0 {
+		losses := float32(0)
+		if m.LossBursts > 0 {
+			losses += m.MeanBurst * float32(m.LossBursts)
+		}
+		if o.LossBursts > 0 {
+			losses += o.MeanBurst * float32(o.LossBursts)
+		}
+		merged.MeanBurst = losses / float32(merged.LossBursts)
+	}
+
+	switch {
+	case m.SinceSuccess < 0:
+		merged.SinceSuccess = o.SinceSuccess
+	case o.SinceSuccess < 0:
+		merged.SinceSuccess = m.SinceSuccess
+	default:
+		merged.SinceSuccess = min(m.SinceSuccess, o.SinceSuccess)
+	}
+
+	if sent := m.PacketsSent + o.PacketsSent; sent > 0 {
+		merged.Availability = (m.Availability*float32(m.PacketsSent) + o.Availability*float32(o.PacketsSent)) / float32(sent)
+	}
+
+	merged.Outages = append(append([]Outage(nil), m.Outages...), o.Outages...)
+	sort.Slice(merged.Outages, func(i, j int) bool {
+		return merged.Outages[i].Start.Before(merged.Outages[j].Start)
+	})
+}
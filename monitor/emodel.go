@@ -0,0 +1,98 @@
+package monitor
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Codec describes the impairments of a voice codec for the E-model
+// (see ITU-T G.113, Appendix I).
+type Codec struct {
+	Name  string
+	Ie    float64       // equipment impairment factor
+	Bpl   float64       // packet-loss robustness factor
+	Delay time.Duration // packetization and look-ahead delay
+}
+
+// Codec profiles, with packet loss concealment and 20ms packets.
+var (
+	CodecG711   = Codec{Name: "G.711", Ie: 0, Bpl: 25.1, Delay: 20 * time.Millisecond}
+	CodecG729A  = Codec{Name: "G.729A", Ie: 11, Bpl: 19, Delay: 25 * time.Millisecond}
+	CodecGSMEFR = Codec{Name: "GSM-EFR", Ie: 5, Bpl: 10, Delay: 20 * time.Millisecond}
+)
+
+// Codecs lists the predefined codec profiles.
+var Codecs = []Codec{CodecG711, CodecG729A, CodecGSMEFR}
+
+// CodecByName looks up a predefined codec profile, ignoring case and
+// punctuation (so "g729a" finds G.729A).
+func CodecByName(name string) (Codec, error) {
+	normalize := strings.NewReplacer(".", "", "-", "", "_", "")
+	key := strings.ToLower(normalize.Replace(name))
+	for _, c := range Codecs {
+		if strings.ToLower(normalize.Replace(c.Name)) == key {
+			return c, nil
+		}
+	}
+	return Codec{}, fmt.Errorf("unknown codec %q", name)
+}
+
+// defaultR is the R-factor of the E-model with the default values of
+// ITU-T G.107, i.e. without any impairments.
+const defaultR = 93.2
+
+// EModel estimates the R-factor (0-100) and MOS (1-4.5) of a voice call
+// over a link with the given mean rtt and jitter (in ms) and loss ratio
+// (0-1), using the simplified ITU-T G.107 E-model with random losses.
+//
+// The one-way delay is assumed to be half the rtt, plus a jitter buffer
+// of twice the jitter and the codec delay. A NaN jitter counts as 0, a
+// NaN rtt (i.e. no replies at all) yields the worst score.
+func EModel(codec Codec, rtt, jitter, loss float64) (r, mos float64) {
+	if math.IsNaN(rtt) {
+		return 0, 1
+	}
+	if math.IsNaN(jitter) {
+		jitter = 0
+	}
+
+	// delay impairment
+	d := rtt/2 + 2*jitter + float64(codec.Delay)/float64(time.Millisecond)
+	id := 0.024 * d
+	if d > 177.3 {
+		id += 0.11 * (d - 177.3)
+	}
+
+	// effective equipment impairment
+	ppl := 100 * loss
+	ie := codec.Ie
+	if ppl > 0 {
+		ie += (95 - codec.Ie) * ppl / (ppl + codec.Bpl)
+	}
+
+	r = math.Max(0, math.Min(100, defaultR-id-ie))
+	return r, mosFromR(r)
+}
+
+// mosFromR converts an R-factor into a MOS (ITU-T G.107, Annex B).
+func mosFromR(r float64) float64 {
+	switch {
+	case r <= 0:
+		return 1
+	case r >= 100:
+		return 4.5
+	}
+	return 1 + 0.035*r + r*(r-60)*(100-r)*7e-6
+}
+
+// Score estimates the R-factor and MOS of the metrics for the given codec
+// (see EModel).
+func (m *Metrics) Score(codec Codec) (r, mos float64) {
+	loss := 0.0
+	if m.PacketsSent > 0 {
+		loss = float64(m.PacketsLost) / float64(m.PacketsSent)
+	}
+	return EModel(codec, float64(m.Mean), float64(m.Jitter), loss)
+}
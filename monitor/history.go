@@ -12,13 +12,24 @@ import (
 type Result struct {
 	RTT  time.Duration
 	Lost bool
+	Sent time.Time // when the request was sent
+	Err  error     // reason of the loss, nil if answered
 }
 
 // History represents the ping history for a single node/device.
 type History struct {
+	Quantiles []float64       // rtt quantiles to compute, e.g. 0.9, 0.99 or 0.999
+	Buckets   []time.Duration // upper bounds of the rtt histogram buckets, ascending
+	Codec     *Codec          // codec for the voice quality score, defaults to CodecG711
+
+	// OutageLosses is the minimum number of consecutive losses counted
+	// as outage rather than packet loss. Defaults to 1.
+	OutageLosses int
+
 	results  []Result
 	count    int
 	position int
+	evicted  time.Time // send time of the last result overwritten
 	sync.RWMutex
 }
 
@@ -29,29 +40,45 @@ func NewHistory(capacity int) History {
 	}
 }
 
-// AddResult saves a ping result into the internal history.
+// AddResult saves a ping result into the internal history, using the
+// current time as send time.
 func (h *History) AddResult(rtt time.Duration, err error) {
+	h.Add(Result{RTT: rtt, Lost: err != nil, Sent: time.Now(), Err: err})
+}
+
+// Add saves a ping result into the internal history. Results are expected
+// in the order they were sent.
+func (h *History) Add(res Result) {
 	h.Lock()
+	h.add(res)
+	h.Unlock()
+}
 
-	h.results[h.position] = Result{RTT: rtt, Lost: err != nil}
+func (h *History) add(res Result) {
+	if len(h.results) == 0 {
+		return // no capacity
+	}
+	if h.count == cap(h.results) {
+		h.evicted = h.results[h.position].Sent
+	}
+	h.results[h.position] = res
 	h.position = (h.position + 1) % cap(h.results)
 
 	if h.count < cap(h.results) {
 		h.count++
 	}
-
-	h.Unlock()
 }
 
 func (h *History) clear() {
 	h.count = 0
 	h.position = 0
+	h.evicted = time.Time{}
 }
 
 // ComputeAndClear aggregates the result history into a single data point and clears the result set.
 func (h *History) ComputeAndClear() *Metrics {
 	h.Lock()
-	result := h.compute()
+	result := h.compute(time.Time{})
 	h.clear()
 	h.Unlock()
 	return result
@@ -61,67 +88,117 @@ func (h *History) ComputeAndClear() *Metrics {
 func (h *History) Compute() *Metrics {
 	h.RLock()
 	defer h.RUnlock()
-	return h.compute()
+	return h.compute(time.Time{})
+}
+
+// ComputeWindow aggregates the results sent within the last d into a
+// single data point. It returns nil if there are none. Only results still
+// kept in the history are considered: if results within the window have
+// been overwritten, Metrics.Partial is set, and Metrics.Start tells how
+// far back the data reaches.
+func (h *History) ComputeWindow(d time.Duration) *Metrics {
+	h.RLock()
+	defer h.RUnlock()
+	return h.compute(time.Now().Add(-d))
+}
+
+// each calls fn for the results sent at or after since, oldest first.
+func (h *History) each(since time.Time, fn func(*Result)) {
+	if h.count == 0 {
+		return
+	}
+	size := len(h.results)
+	oldest := (h.position - h.count + size) % size
+
+	for i := 0; i < h.count; i++ {
+		res := &h.results[(oldest+i)%size]
+		if !res.Sent.Before(since) {
+			fn(res)
+		}
+	}
 }
 
-func (h *History) compute() *Metrics {
+// compute aggregates the results sent at or after since.
+func (h *History) compute(since time.Time) *Metrics {
 	numFailure := 0
-	numTotal := h.count
+	numTotal := 0
 	µsPerMs := 1.0 / float64(time.Millisecond)
 
+	var start, end time.Time
+	data := make([]float64, 0, h.count)
+	h.each(since, func(res *Result) {
+		if numTotal == 0 {
+			start = res.Sent
+		}
+		end = res.Sent
+		numTotal++
+		if res.Lost {
+			numFailure++
+		} else {
+			data = append(data, float64(res.RTT)*µsPerMs)
+		}
+	})
 	if numTotal == 0 {
 		return nil
 	}
+	sort.Float64s(data)
 
-	data := make([]float64, 0, numTotal)
-	var best, worst, mean, stddev, total, sumSquares float64
-	var extremeFound bool
+	jitter, ipdvMean, ipdvMax := h.delayVariation(since)
 
-	for i := 0; i < numTotal; i++ {
-		curr := &h.results[i]
-		if curr.Lost {
-			numFailure++
-		} else {
-			rtt := float64(curr.RTT) * µsPerMs
-			data = append(data, rtt)
-
-			if !extremeFound || rtt < best {
-				best = rtt
-			}
-			if !extremeFound || rtt > worst {
-				worst = rtt
-			}
-
-			extremeFound = true
-			total += rtt
+	m := &Metrics{
+		PacketsSent: numTotal,
+		PacketsLost: numFailure,
+		Start:       start,
+		End:         end,
+		Partial:     !since.IsZero() && !h.evicted.Before(since),
+		Jitter:      float32(jitter),
+		IPDVMean:    float32(ipdvMean),
+		IPDVMax:     float32(ipdvMax),
+	}
+	if len(h.Buckets) > 0 {
+		m.Histogram = &Histogram{Bounds: make([]float32, len(h.Buckets))}
+		for i, b := range h.Buckets {
+			m.Histogram.Bounds[i] = float32(float64(b) * µsPerMs)
 		}
 	}
+	m.setRTTs(data, h.Quantiles)
+	m.setAvailability(h, since, h.OutageLosses)
 
-	size := float64(numTotal - numFailure)
-	mean = total / size
-	for _, rtt := range data {
-		diff := rtt - mean
-		sumSquares += diff * diff
+	codec := CodecG711
+	if h.Codec != nil {
+		codec = *h.Codec
 	}
-	stddev = math.Sqrt(sumSquares / size)
+	m.setScore(codec)
+	return m
+}
 
-	median := math.NaN()
-	if l := len(data); l > 0 {
-		sort.Float64Slice(data).Sort()
-		if l%2 == 0 {
-			median = (data[l/2-1] + data[l/2]) / 2
-		} else {
-			median = data[l/2]
+// delayVariation computes the RFC 3550 interarrival jitter, and the mean
+// and maximum absolute difference (IPDV) of the rtt of consecutive
+// received results in sample order. Lost results are skipped. All values
+// are NaN if less than two results were received.
+func (h *History) delayVariation(since time.Time) (jitter, ipdvMean, ipdvMax float64) {
+	µsPerMs := 1.0 / float64(time.Millisecond)
+
+	var prev, total float64
+	var received int
+	h.each(since, func(res *Result) {
+		if res.Lost {
+			return
 		}
-	}
 
-	return &Metrics{
-		PacketsSent: numTotal,
-		PacketsLost: numFailure,
-		Best:        float32(best),
-		Worst:       float32(worst),
-		Median:      float32(median),
-		Mean:        float32(mean),
-		StdDev:      float32(stddev),
+		rtt := float64(res.RTT) * µsPerMs
+		if received > 0 {
+			d := math.Abs(rtt - prev)
+			jitter += (d - jitter) / 16
+			total += d
+			ipdvMax = math.Max(ipdvMax, d)
+		}
+		prev = rtt
+		received++
+	})
+
+	if received < 2 {
+		return math.NaN(), math.NaN(), math.NaN()
 	}
+	return jitter, total / float64(received-1), ipdvMax
 }
@@ -143,3 +143,252 @@ func TestHistoryCapacity(t *testing.T) {
 	assert.Equal(h.count, 0)
 	assert.Equal(h.position, 0)
 }
+
+func TestHistoryEmpty(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, capacity := range []int{0, 3} {
+		h := NewHistory(capacity)
+		assert.Nil(h.Compute(), capacity)
+		assert.Nil(h.ComputeWindow(time.Minute), capacity)
+		assert.Nil(h.ComputeAndClear(), capacity)
+	}
+
+	h := NewHistory(0)
+	h.AddResult(time.Millisecond, nil)
+	assert.Nil(h.Compute())
+}
+
+func TestDelayVariation(t *testing.T) {
+	assert := assert.New(t)
+	const ms = time.Millisecond
+	err := fmt.Errorf("i/o timeout")
+
+	{ // undefined for less than two replies
+		h := NewHistory(4)
+		h.AddResult(10*ms, nil)
+		h.AddResult(0, err)
+
+		metrics := h.Compute()
+		assert.True(math.IsNaN(float64(metrics.Jitter)))
+		assert.True(math.IsNaN(float64(metrics.IPDVMean)))
+		assert.True(math.IsNaN(float64(metrics.IPDVMax)))
+	}
+
+	{ // lost packets are skipped
+		h := NewHistory(8)
+		h.AddResult(10*ms, nil)
+		h.AddResult(20*ms, nil)
+		h.AddResult(0, err)
+		h.AddResult(15*ms, nil)
+		h.AddResult(15*ms, nil)
+
+		metrics := h.Compute()
+		assert.InDelta(0.842285, float64(metrics.Jitter), 0.000001)
+		assert.EqualValues(5, metrics.IPDVMean)
+		assert.EqualValues(10, metrics.IPDVMax)
+	}
+
+	{ // in sample order after wrapping around
+		h := NewHistory(3)
+		h.AddResult(10*ms, nil)
+		h.AddResult(20*ms, nil)
+		h.AddResult(40*ms, nil)
+		h.AddResult(80*ms, nil)
+
+		metrics := h.Compute()
+		assert.EqualValues(30, metrics.IPDVMean)
+		assert.EqualValues(40, metrics.IPDVMax)
+	}
+}
+
+func TestQuantiles(t *testing.T) {
+	assert := assert.New(t)
+	const ms = time.Millisecond
+
+	h := NewHistory(12)
+	h.Quantiles = []float64{0.9, 0.99}
+	h.Buckets = []time.Duration{2 * ms, 5 * ms}
+	for i := 1; i <= 10; i++ {
+		h.AddResult(time.Duration(i)*ms, nil)
+	}
+	h.AddResult(0, fmt.Errorf("i/o timeout"))
+
+	metrics := h.Compute()
+	assert.EqualValues(5.5, metrics.Median)
+	assert.Equal([]Quantile{{0.9, 9.1}, {0.99, 9.91}}, metrics.Quantiles)
+	assert.Equal(&Histogram{Bounds: []float32{2, 5}, Counts: []int{2, 3, 5}}, metrics.Histogram)
+
+	// no replies
+	h = NewHistory(2)
+	h.Quantiles = []float64{0.9}
+	h.AddResult(0, fmt.Errorf("i/o timeout"))
+	metrics = h.Compute()
+	assert.True(math.IsNaN(float64(metrics.Quantiles[0].Value)))
+}
+
+func TestMerge(t *testing.T) {
+	assert := assert.New(t)
+	const ms = time.Millisecond
+	err := fmt.Errorf("i/o timeout")
+
+	a := NewHistory(4)
+	a.Quantiles = []float64{0.5}
+	a.Buckets = []time.Duration{2 * ms}
+	a.AddResult(1*ms, nil)
+	a.AddResult(3*ms, nil)
+
+	b := NewHistory(4)
+	b.AddResult(2*ms, nil)
+	b.AddResult(6*ms, nil)
+	b.AddResult(0, err)
+
+	merged := a.Compute().Merge(b.Compute())
+	assert.EqualValues(5, merged.PacketsSent)
+	assert.EqualValues(1, merged.PacketsLost)
+	assert.EqualValues(1, merged.Best)
+	assert.EqualValues(6, merged.Worst)
+	assert.EqualValues(3, merged.Mean)
+	assert.EqualValues(2.5, merged.Median)
+	assert.InDelta(1.870829, float64(merged.StdDev), 0.000001)
+	assert.Equal([]Quantile{{0.5, 2.5}}, merged.Quantiles)
+	assert.Equal([]int{2, 2}, merged.Histogram.Counts)
+	assert.EqualValues(3, merged.IPDVMean) // (2*2 + 2*4) / 4
+	assert.EqualValues(4, merged.IPDVMax)
+
+	// lost-only metrics and nil
+	c := NewHistory(2)
+	c.AddResult(0, err)
+	assert.Equal(merged.Merge(c.Compute()).PacketsSent, 6)
+	assert.Equal(merged, merged.Merge(nil))
+	assert.Nil((*Metrics)(nil).Merge(nil))
+	assert.EqualValues(2, (*Metrics)(nil).Merge(b.Compute()).Best)
+}
+
+func TestComputeWindow(t *testing.T) {
+	assert := assert.New(t)
+	const ms = time.Millisecond
+	now := time.Now()
+	timeout := fmt.Errorf("i/o timeout")
+
+	h := NewHistory(8)
+	h.Add(Result{RTT: 50 * ms, Sent: now.Add(-10 * time.Minute)})
+	h.Add(Result{Lost: true, Err: timeout, Sent: now.Add(-6 * time.Minute)})
+	h.Add(Result{RTT: 10 * ms, Sent: now.Add(-4 * time.Minute)})
+	h.Add(Result{Lost: true, Err: timeout, Sent: now.Add(-2 * time.Minute)})
+	h.Add(Result{RTT: 20 * ms, Sent: now.Add(-time.Minute)})
+
+	metrics := h.ComputeWindow(5 * time.Minute)
+	assert.EqualValues(3, metrics.PacketsSent)
+	assert.EqualValues(1, metrics.PacketsLost)
+	assert.EqualValues(10, metrics.Best)
+	assert.EqualValues(20, metrics.Worst)
+	assert.EqualValues(10, metrics.IPDVMean)
+
+	assert.EqualValues(5, h.Compute().PacketsSent)
+	assert.Nil(h.ComputeWindow(30 * time.Second))
+	assert.False(metrics.Partial)
+	assert.Equal(now.Add(-4*time.Minute), metrics.Start)
+	assert.Equal(now.Add(-time.Minute), metrics.End)
+
+	// the window reaches beyond the capacity
+	h = NewHistory(3)
+	for i := 5; i > 0; i-- {
+		h.Add(Result{RTT: 10 * ms, Sent: now.Add(-time.Duration(i) * time.Minute)})
+	}
+	metrics = h.ComputeWindow(10 * time.Minute)
+	assert.True(metrics.Partial)
+	assert.EqualValues(3, metrics.PacketsSent)
+	assert.Equal(now.Add(-3*time.Minute), metrics.Start)
+	assert.False(h.ComputeWindow(150 * time.Second).Partial)
+	assert.False(h.Compute().Partial)
+}
+
+func TestEModel(t *testing.T) {
+	assert := assert.New(t)
+
+	// perfect link
+	r, mos := EModel(CodecG711, 0, 0, 0)
+	assert.InDelta(92.72, r, 0.01) // only the codec delay of 20ms
+	assert.InDelta(4.40, mos, 0.01)
+
+	// 100ms rtt, 10ms jitter and 1% loss
+	r, mos = EModel(CodecG711, 100, 10, 0.01)
+	assert.InDelta(87.40, r, 0.01) // 93.2 - 0.024*(50+20+20) - 95*1/(1+25.1)
+	assert.InDelta(4.27, mos, 0.01)
+
+	// worse codec under the same conditions
+	r2, mos2 := EModel(CodecG729A, 100, 10, 0.01)
+	assert.Less(r2, r)
+	assert.Less(mos2, mos)
+
+	// long delays are punished harder
+	r, _ = EModel(CodecG711, 400, 0, 0)
+	assert.InDelta(93.2-0.024*220-0.11*42.7, r, 0.01)
+
+	// no replies at all
+	r, mos = EModel(CodecG711, math.NaN(), math.NaN(), 1)
+	assert.EqualValues(0, r)
+	assert.EqualValues(1, mos)
+
+	codec, err := CodecByName("g729a")
+	assert.NoError(err)
+	assert.Equal(CodecG729A, codec)
+	_, err = CodecByName("Opus-HD")
+	assert.EqualError(err, `unknown codec "Opus-HD"`)
+
+	// computed with the codec of the history
+	h := NewHistory(4)
+	h.AddResult(100*time.Millisecond, nil)
+	h.AddResult(100*time.Millisecond, nil)
+	r, mos = h.Compute().Score(CodecG711)
+	assert.InDelta(r, float64(h.Compute().RFactor), 0.001)
+	assert.InDelta(mos, float64(h.Compute().MOS), 0.001)
+
+	h.Codec = &CodecG729A
+	assert.Less(h.Compute().MOS, float32(mos))
+}
+
+func TestAvailability(t *testing.T) {
+	assert := assert.New(t)
+	const ms = time.Millisecond
+	now := time.Now()
+	timeout := fmt.Errorf("i/o timeout")
+
+	// ok, lost, ok, lost, lost, lost, ok, lost, lost, lost
+	h := NewHistory(10)
+	h.OutageLosses = 3
+	for i, lost := range []bool{false, true, false, true, true, true, false, true, true, true} {
+		res := Result{RTT: 10 * ms, Sent: now.Add(time.Duration(i-10) * time.Second)}
+		if lost {
+			res = Result{Lost: true, Err: timeout, Sent: res.Sent}
+		}
+		h.Add(res)
+	}
+
+	metrics := h.Compute()
+	assert.EqualValues(3, metrics.LossBursts)
+	assert.EqualValues(3, metrics.LongestBurst)
+	assert.InDelta(7.0/3, metrics.MeanBurst, 0.001)
+	assert.InDelta(4*time.Second, metrics.SinceSuccess, float64(time.Second))
+	assert.EqualValues(40, metrics.Availability)
+
+	if assert.Len(metrics.Outages, 2) {
+		assert.Equal(Outage{Start: now.Add(-7 * time.Second), End: now.Add(-4 * time.Second), Lost: 3}, metrics.Outages[0])
+		assert.Equal(now.Add(-3*time.Second), metrics.Outages[1].Start)
+		assert.True(metrics.Outages[1].End.IsZero())
+	}
+
+	// shorter loss runs are no outages
+	h.OutageLosses = 4
+	lenient := h.Compute()
+	assert.EqualValues(3, lenient.LossBursts)
+	assert.Empty(lenient.Outages)
+	assert.EqualValues(100, lenient.Availability)
+
+	merged := metrics.Merge(nil)
+	assert.EqualValues(40, merged.Availability)
+	assert.Equal(metrics.SinceSuccess, merged.SinceSuccess)
+	assert.EqualValues(3, merged.LossBursts)
+	assert.Len(merged.Outages, 2)
+}
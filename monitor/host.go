@@ -0,0 +1,222 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/digineo/go-ping"
+)
+
+const (
+	minResolveInterval = 10 * time.Second // lower bound for TTLs and retries
+	resolveTimeout     = 10 * time.Second
+)
+
+// A TTLResolver is a resolver which also reports how long the addresses
+// may be cached.
+type TTLResolver interface {
+	ping.Resolver
+	LookupIPAddrTTL(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error)
+}
+
+// HostStatus describes the name resolution of a host target.
+type HostStatus struct {
+	Host     string       // host name
+	Addrs    []net.IPAddr // currently monitored addresses
+	Err      error        // error of the last lookup, nil if it succeeded
+	Resolved time.Time    // time of the last successful lookup
+}
+
+// hostTarget is a host name whose addresses are monitored as individual
+// targets.
+type hostTarget struct {
+	status HostStatus
+	opts   TargetOptions         // of the address targets
+	keys   map[string]net.IPAddr // address targets by key
+	stop   chan struct{}
+}
+
+// AddressKey returns the key under which the address addr of the host
+// target with the given key is exported. Use HostOf instead of parsing
+// it, as keys may contain any character.
+func AddressKey(key string, addr net.IPAddr) string {
+	return key + "/" + addr.String()
+}
+
+// HostOf returns the key of the host target and the address of an
+// address target, or false if key belongs to no host target.
+func (p *Monitor) HostOf(key string) (string, net.IPAddr, bool) {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	for hostKey, h := range p.hosts {
+		if addr, ok := h.keys[key]; ok {
+			return hostKey, addr, true
+		}
+	}
+	return "", net.IPAddr{}, false
+}
+
+// AddHost adds a host target to the monitored list. The host name is
+// resolved periodically, and each of its addresses is monitored as a
+// target named by AddressKey. Addresses are added and removed as the DNS
+// changes, keeping the history of unchanged addresses.
+//
+// If the target with the given key already exists, it is removed first.
+func (p *Monitor) AddHost(key, host string) error {
+	return p.AddHostWithOptions(key, host, TargetOptions{})
+}
+
+// AddHostWithOptions is AddHost with options for the address targets. The
+// StartupDelay applies to each address when it is added.
+func (p *Monitor) AddHostWithOptions(key, host string, opts TargetOptions) error {
+	if host == "" {
+		return errors.New("empty host name")
+	}
+	opts, err := p.withDefaults(opts)
+	if err != nil {
+		return err
+	}
+
+	h := &hostTarget{
+		status: HostStatus{Host: host},
+		opts:   opts,
+		keys:   make(map[string]net.IPAddr),
+		stop:   make(chan struct{}),
+	}
+
+	p.mtx.Lock()
+	p.removeTarget(key)
+	p.hosts[key] = h
+	p.mtx.Unlock()
+
+	go p.runHost(key, h)
+	return nil
+}
+
+// Hosts returns the resolution status of all host targets.
+func (p *Monitor) Hosts() map[string]HostStatus {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	m := make(map[string]HostStatus, len(p.hosts))
+	for key, h := range p.hosts {
+		status := h.status
+		status.Addrs = append([]net.IPAddr(nil), status.Addrs...)
+		m[key] = status
+	}
+	return m
+}
+
+func (p *Monitor) runHost(key string, h *hostTarget) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-timer.C:
+			timer.Reset(p.resolveHost(ctx, key, h))
+		}
+	}
+}
+
+// resolveHost looks up the addresses of the host and updates the address
+// targets. It returns the delay until the next lookup.
+func (p *Monitor) resolveHost(ctx context.Context, key string, h *hostTarget) time.Duration {
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	addrs, ttl, err := p.lookup(ctx, h.status.Host)
+	cancel()
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.hosts[key] != h {
+		return p.ResolveInterval // removed in the meantime
+	}
+
+	if err == nil && len(addrs) == 0 {
+		err = errors.New("no addresses found for " + h.status.Host)
+	}
+	if err != nil {
+		// keep monitoring the known addresses
+		p.logger().Warn("resolving host failed", "target", key, "host", h.status.Host, "error", err)
+		h.status.Err = err
+		return minResolveInterval
+	}
+
+	h.status.Err = nil
+	h.status.Resolved = time.Now()
+	h.status.Addrs = addrs
+
+	current := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		k := AddressKey(key, addr)
+		current[k] = true
+		if _, ok := h.keys[k]; !ok {
+			opts := h.opts
+			if err := p.addTarget(k, addr, &opts); err != nil {
+				p.logger().Warn("adding address failed", "target", key, "address", addr.String(), "error", err)
+				continue
+			}
+			h.keys[k] = addr
+		}
+	}
+	for k := range h.keys {
+		if !current[k] {
+			p.removeTarget(k)
+			delete(h.keys, k)
+		}
+	}
+
+	if ttl <= 0 {
+		return p.ResolveInterval
+	}
+	if ttl < minResolveInterval {
+		return minResolveInterval
+	}
+	return ttl
+}
+
+// lookup resolves the host using the configured resolver.
+func (p *Monitor) lookup(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+	var addrs []net.IPAddr
+	var ttl time.Duration
+	var err error
+
+	switch r := p.Resolver.(type) {
+	case TTLResolver:
+		addrs, ttl, err = r.LookupIPAddrTTL(ctx, host)
+	case nil:
+		addrs, err = net.DefaultResolver.LookupIPAddr(ctx, host)
+	default:
+		addrs, err = r.LookupIPAddr(ctx, host)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	addrs = append([]net.IPAddr(nil), addrs...)
+	sort.Slice(addrs, func(i, j int) bool {
+		return addrs[i].String() < addrs[j].String()
+	})
+	return addrs, ttl, nil
+}
+
+// removeHost stops resolving the host and removes its address targets.
+// Needs to be locked externally!
+func (p *Monitor) removeHost(key string, h *hostTarget) {
+	close(h.stop)
+	delete(p.hosts, key)
+
+	for k := range h.keys {
+		p.removeTarget(k)
+	}
+}
@@ -1,12 +1,200 @@
 package monitor
 
+import (
+	"math"
+	"sort"
+	"time"
+)
+
 // Metrics is a dumb data point computed from a history of Results.
 type Metrics struct {
-	PacketsSent int     // number of packets sent
-	PacketsLost int     // number of packets lost
-	Best        float32 // best rtt in ms
-	Worst       float32 // worst rtt in ms
-	Median      float32 // median rtt in ms
-	Mean        float32 // mean rtt in ms
-	StdDev      float32 // std deviation in ms
+	PacketsSent int        // number of packets sent
+	PacketsLost int        // number of packets lost
+	Start       time.Time  // send time of the oldest result
+	End         time.Time  // send time of the newest result
+	Partial     bool       // whether results within the window of ComputeWindow were overwritten
+	Best        float32    // best rtt in ms
+	Worst       float32    // worst rtt in ms
+	Median      float32    // median rtt in ms
+	Mean        float32    // mean rtt in ms
+	StdDev      float32    // std deviation in ms
+	Jitter      float32    // RFC 3550 interarrival jitter in ms
+	IPDVMean    float32    // mean absolute rtt difference of consecutive replies in ms
+	IPDVMax     float32    // maximum absolute rtt difference of consecutive replies in ms
+	Quantiles   []Quantile // rtt quantiles, as configured in History.Quantiles
+	Histogram   *Histogram // rtt distribution, if History.Buckets is configured
+	RFactor     float32    // E-model R-factor (0-100) for History.Codec
+	MOS         float32    // mean opinion score (1-4.5) for History.Codec
+
+	LossBursts   int           // number of runs of consecutive losses
+	LongestBurst int           // length of the longest run of consecutive losses
+	MeanBurst    float32       // mean length of the loss runs, NaN if none
+	SinceSuccess time.Duration // time since the last answered request was sent, -1 if none
+	Availability float32       // percentage of results not part of an outage
+	Outages      []Outage      // loss runs of at least History.OutageLosses results
+
+	rtts  []float64 // sorted rtts in ms, for merging
+	codec Codec     // codec of RFactor and MOS, for merging
+}
+
+// Quantile is the rtt below which the fraction Q of the replies fall.
+type Quantile struct {
+	Q     float64 // e.g. 0.99
+	Value float32 // rtt in ms
+}
+
+// Histogram counts rtts in buckets.
+type Histogram struct {
+	Bounds []float32 // upper bounds of the buckets in ms, ascending
+	Counts []int     // rtts per bucket, the last entry counts those above all bounds
+}
+
+// Merge combines the metrics of different targets or reporting windows.
+// The rtt statistics, quantiles and histogram are computed exactly from
+// the rtts of both. Jitter and IPDVMean are approximated by the mean
+// weighted by the number of replies, Availability by the number of
+// results. Outages are concatenated, Start and End span both. The
+// quantiles and histogram bounds of m are used, or those of o if m has
+// none.
+//
+// Both m and o are left unchanged. Either may be nil.
+func (m *Metrics) Merge(o *Metrics) *Metrics {
+	if m == nil {
+		m, o = o, m
+	}
+	if m == nil {
+		return nil
+	}
+	if o == nil {
+		nan := float32(math.NaN())
+		o = &Metrics{Jitter: nan, IPDVMean: nan, IPDVMax: nan, SinceSuccess: -1}
+	}
+
+	merged := &Metrics{
+		PacketsSent: m.PacketsSent + o.PacketsSent,
+		PacketsLost: m.PacketsLost + o.PacketsLost,
+		Start:       m.Start,
+		End:         m.End,
+		Partial:     m.Partial || o.Partial,
+	}
+	if !o.Start.IsZero() && (merged.Start.IsZero() || o.Start.Before(merged.Start)) {
+		merged.Start = o.Start
+	}
+	if o.End.After(merged.End) {
+		merged.End = o.End
+	}
+
+	// weights are the number of replies, if the values are defined
+	wm, wo := float64(len(m.rtts)), float64(len(o.rtts))
+	combine := func(a, b float32, f func(a, b float64) float64) float32 {
+		switch {
+		case math.IsNaN(float64(a)):
+			return b
+		case math.IsNaN(float64(b)):
+			return a
+		}
+		return float32(f(float64(a), float64(b)))
+	}
+	weighted := func(a, b float64) float64 {
+		return (a*wm + b*wo) / (wm + wo)
+	}
+	merged.Jitter = combine(m.Jitter, o.Jitter, weighted)
+	merged.IPDVMean = combine(m.IPDVMean, o.IPDVMean, weighted)
+	merged.IPDVMax = combine(m.IPDVMax, o.IPDVMax, math.Max)
+	mergeAvailability(merged, m, o)
+
+	quantiles := m.Quantiles
+	if len(quantiles) == 0 {
+		quantiles = o.Quantiles
+	}
+	qs := make([]float64, len(quantiles))
+	for i, q := range quantiles {
+		qs[i] = q.Q
+	}
+
+	histogram := m.Histogram
+	if histogram == nil {
+		histogram = o.Histogram
+	}
+	if histogram != nil {
+		merged.Histogram = &Histogram{Bounds: histogram.Bounds}
+	}
+
+	rtts := make([]float64, 0, len(m.rtts)+len(o.rtts))
+	rtts = append(append(rtts, m.rtts...), o.rtts...)
+	sort.Float64s(rtts)
+	merged.setRTTs(rtts, qs)
+	codec := m.codec
+	if codec.Name == "" {
+		codec = o.codec
+	}
+	merged.setScore(codec)
+
+	return merged
+}
+
+// setRTTs computes the rtt statistics from the sorted rtts (in ms). If
+// m.Histogram is set, its counts are filled according to its bounds.
+func (m *Metrics) setRTTs(rtts []float64, quantiles []float64) {
+	m.rtts = rtts
+
+	var total, sumSquares float64
+	for _, rtt := range rtts {
+		total += rtt
+	}
+	size := float64(len(rtts))
+	mean := total / size
+	for _, rtt := range rtts {
+		diff := rtt - mean
+		sumSquares += diff * diff
+	}
+
+	if len(rtts) > 0 {
+		m.Best = float32(rtts[0])
+		m.Worst = float32(rtts[len(rtts)-1])
+	}
+	m.Mean = float32(mean)
+	m.StdDev = float32(math.Sqrt(sumSquares / size))
+	m.Median = float32(quantile(rtts, 0.5))
+
+	m.Quantiles = nil
+	for _, q := range quantiles {
+		m.Quantiles = append(m.Quantiles, Quantile{Q: q, Value: float32(quantile(rtts, q))})
+	}
+
+	if m.Histogram != nil {
+		m.Histogram.Counts = make([]int, len(m.Histogram.Bounds)+1)
+		for _, rtt := range rtts {
+			i := sort.Search(len(m.Histogram.Bounds), func(i int) bool {
+				return rtt <= float64(m.Histogram.Bounds[i])
+			})
+			m.Histogram.Counts[i]++
+		}
+	}
+}
+
+// quantile interpolates linearly between the closest ranks of the sorted
+// values. It returns NaN for empty values.
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+
+	pos := q * float64(len(sorted)-1)
+	i := int(pos)
+	if i >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	if i < 0 {
+		return sorted[0]
+	}
+	return sorted[i] + (pos-float64(i))*(sorted[i+1]-sorted[i])
+}
+
+// setScore computes RFactor and MOS for the given codec.
+func (m *Metrics) setScore(codec Codec) {
+	r, mos := m.Score(codec)
+	m.codec = codec
+	m.RFactor = float32(r)
+	m.MOS = float32(mos)
 }
@@ -1,7 +1,12 @@
 package monitor
 
 import (
+	"fmt"
+	"log/slog"
 	"net"
+	"net/url"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
@@ -10,16 +15,68 @@ import (
 
 // Monitor manages the goroutines responsible for collecting Ping RTT data.
 type Monitor struct {
-	HistorySize int // Number of results per target to keep
+	HistorySize int // Number of results per target to keep, unless set in TargetOptions
+
+	// Quantiles and HistogramBuckets configure the rtt distribution in
+	// the Metrics (see History). They are picked up when a target is
+	// added.
+	Quantiles        []float64
+	HistogramBuckets []time.Duration
+
+	// Codec selects the codec profile for the voice quality score in the
+	// Metrics. Defaults to CodecG711.
+	Codec *Codec
+
+	// OnResult is called with the key and result of every single ping, if
+	// set. It is picked up when a target is added.
+	OnResult func(key string, res Result)
+
+	// ResultLog receives every single result, if set. It is picked up
+	// when a target is added, and not closed by Stop.
+	ResultLog *ResultLog
+
+	// DownAfter and UpAfter are the number of consecutive losses and
+	// replies after which a target is considered down or up. They are
+	// picked up when a target is added.
+	DownAfter int
+	UpAfter   int
+
+	// OnStateChange is called on every state transition of a target, if
+	// set. It is picked up when a target is added. See also Subscribe.
+	OnStateChange func(StateEvent)
+
+	// Resolver looks up the addresses of host targets. Defaults to
+	// net.DefaultResolver; a TTLResolver's TTLs are respected.
+	Resolver ping.Resolver
+
+	// ResolveInterval is the interval between lookups of host targets
+	// (unless a TTLResolver reports a TTL).
+	ResolveInterval time.Duration
+
+	// Logger receives failed pings and lookups. Defaults to slog.Default()
+	// when a target is added.
+	Logger *slog.Logger
 
 	pinger   *ping.Pinger
 	interval time.Duration
 	targets  map[string]*Target
+	hosts    map[string]*hostTarget
 	mtx      sync.RWMutex
 	timeout  time.Duration
+
+	subscribers map[chan StateEvent]struct{}
+	subMtx      sync.Mutex
+
+	restored    map[string]targetSnapshot // not yet restored snapshots
+	persistPath string
+	persistStop chan struct{}
+	persistDone chan struct{}
 }
 
-const defaultHistorySize = 10
+const (
+	defaultHistorySize     = 10
+	defaultResolveInterval = 5 * time.Minute
+)
 
 // New creates and configures a new Ping instance. You need to call
 // AddTarget()/RemoveTarget() to manage monitored targets.
@@ -29,18 +86,29 @@ func New(pinger *ping.Pinger, interval, timeout time.Duration) *Monitor {
 		interval:    interval,
 		timeout:     timeout,
 		targets:     make(map[string]*Target),
+		hosts:       make(map[string]*hostTarget),
 		HistorySize: defaultHistorySize,
+		DownAfter:   defaultDownAfter,
+		UpAfter:     defaultUpAfter,
+
+		ResolveInterval: defaultResolveInterval,
 	}
 }
 
 // Stop brings the monitoring gracefully to a halt.
 func (p *Monitor) Stop() {
+	p.stopPersist()
+
 	p.mtx.Lock()
+	for id := range p.hosts {
+		p.removeTarget(id)
+	}
 	for id := range p.targets {
 		p.removeTarget(id)
 	}
 	p.pinger.Close()
 	p.mtx.Unlock()
+	p.closeSubscribers()
 }
 
 // AddTarget adds a target to the monitored list. If the target with the given
@@ -52,16 +120,117 @@ func (p *Monitor) AddTarget(key string, addr net.IPAddr) (err error) {
 
 // AddTargetDelayed is AddTarget with a startup delay
 func (p *Monitor) AddTargetDelayed(key string, addr net.IPAddr, startupDelay time.Duration) (err error) {
+	return p.AddTargetWithOptions(key, addr, TargetOptions{StartupDelay: startupDelay})
+}
+
+// AddTargetURL adds a target given as URL, with a startup delay. Supported
+// are icmp://host, tcp://host:port and udp://host:port, where host is an
+// IP address or a host name (which is resolved once).
+func (p *Monitor) AddTargetURL(key, target string, startupDelay time.Duration) error {
+	prober, addr, err := p.parseTarget(target)
+	if err != nil {
+		return err
+	}
+	return p.AddTargetWithProber(key, *addr, prober, startupDelay)
+}
+
+// AddTargetWithProber is AddTargetDelayed with a custom prober, e.g. a
+// ping.TCPProber for hosts filtering ICMP, or a Pinger of another network
+// namespace (see ping.NewInNamespace). Such Pingers are not closed by Stop.
+func (p *Monitor) AddTargetWithProber(key string, addr net.IPAddr, prober ping.Prober, startupDelay time.Duration) (err error) {
+	return p.AddTargetWithOptions(key, addr, TargetOptions{Prober: prober, StartupDelay: startupDelay})
+}
+
+// AddTargetWithOptions is AddTarget with per-target options, e.g. a
+// shorter interval for core routers than for customer devices.
+func (p *Monitor) AddTargetWithOptions(key string, addr net.IPAddr, opts TargetOptions) error {
+	opts, err := p.withDefaults(opts)
+	if err != nil {
+		return err
+	}
+
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
 
-	target, err := newTarget(p.interval, p.timeout, startupDelay, p.HistorySize, p.pinger, addr)
+	p.removeTarget(key)
+	return p.addTarget(key, addr, &opts)
+}
+
+// addTarget starts monitoring a target with options filled in by
+// withDefaults. Needs to be locked externally!
+func (p *Monitor) addTarget(key string, addr net.IPAddr, opts *TargetOptions) error {
+	logger := p.logger().With("target", key, "destination", addr.String())
+
+	var onResult func(Result)
+	if handler, resultLog := p.OnResult, p.ResultLog; handler != nil || resultLog != nil {
+		onResult = func(res Result) {
+			if resultLog != nil {
+				if err := resultLog.Write(key, addr, res); err != nil {
+					logger.Warn("writing result log failed", "error", err)
+				}
+			}
+			if handler != nil {
+				handler(key, res)
+			}
+		}
+	}
+
+	handler := p.OnStateChange
+	onState := func(ev StateEvent) {
+		ev.Key = key
+		p.publish(ev, handler)
+	}
+
+	state := newStateMachine(p.DownAfter, p.UpAfter)
+	target, err := newTarget(opts, p.newHistory(opts.HistorySize), state, addr, onResult, onState, logger)
 	if err != nil {
 		return err
 	}
-	p.removeTarget(key)
+	p.restoreTarget(key, target)
 	p.targets[key] = target
-	return
+	return nil
+}
+
+// newHistory creates the history of a new target.
+func (p *Monitor) newHistory(size int) *History {
+	h := NewHistory(size)
+	h.Quantiles = p.Quantiles
+	h.Buckets = p.HistogramBuckets
+	h.Codec = p.Codec
+	return &h
+}
+
+func (p *Monitor) logger() *slog.Logger {
+	if p.Logger != nil {
+		return p.Logger
+	}
+	return slog.Default()
+}
+
+// Targets returns the keys of the monitored targets, including the
+// address targets of hosts (see AddressKey).
+func (p *Monitor) Targets() []string {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	keys := make([]string, 0, len(p.targets))
+	for key := range p.targets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Labels returns the labels of the target with the given key (see
+// TargetOptions), or nil if it does not exist.
+func (p *Monitor) Labels(key string) map[string]string {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	if target, ok := p.targets[key]; ok {
+		return target.Labels()
+	}
+	return nil
 }
 
 // RemoveTarget removes a target from the monitoring list.
@@ -74,6 +243,11 @@ func (p *Monitor) RemoveTarget(key string) {
 // Stops monitoring a target and removes it from the list (if the list includes
 // the target). Needs to be locked externally!
 func (p *Monitor) removeTarget(key string) {
+	if host, found := p.hosts[key]; found {
+		p.removeHost(key, host)
+		return
+	}
+
 	target, found := p.targets[key]
 	if !found {
 		return
@@ -93,6 +267,23 @@ func (p *Monitor) Export() map[string]*Metrics {
 	return p.export(false)
 }
 
+// ExportWindow calculates the metrics of the results sent within the last
+// d for each monitored target. The HistorySize must be large enough to
+// cover the window.
+func (p *Monitor) ExportWindow(d time.Duration) map[string]*Metrics {
+	m := make(map[string]*Metrics)
+
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	for id, target := range p.targets {
+		if metrics := target.ComputeWindow(d); metrics != nil {
+			m[id] = metrics
+		}
+	}
+	return m
+}
+
 func (p *Monitor) export(clear bool) map[string]*Metrics {
 	m := make(map[string]*Metrics)
 
@@ -106,3 +297,44 @@ func (p *Monitor) export(clear bool) map[string]*Metrics {
 	}
 	return m
 }
+
+// parseTarget converts a target URL into a prober and address.
+func (p *Monitor) parseTarget(target string) (ping.Prober, *net.IPAddr, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	port := 0
+	if u.Port() != "" {
+		if port, err = strconv.Atoi(u.Port()); err != nil || port < 1 || port > 65535 {
+			return nil, nil, fmt.Errorf("invalid port in %q", target)
+		}
+	}
+
+	var prober ping.Prober
+	switch u.Scheme {
+	case "icmp":
+		if port != 0 {
+			return nil, nil, fmt.Errorf("unexpected port in %q", target)
+		}
+		prober = p.pinger
+	case "tcp", "udp":
+		if port == 0 {
+			return nil, nil, fmt.Errorf("missing port in %q", target)
+		}
+		if u.Scheme == "tcp" {
+			prober = &ping.TCPProber{Port: port}
+		} else {
+			prober = &ping.UDPProber{Port: port}
+		}
+	default:
+		return nil, nil, fmt.Errorf("unsupported scheme in %q", target)
+	}
+
+	addr, err := net.ResolveIPAddr("ip", u.Hostname())
+	if err != nil {
+		return nil, nil, err
+	}
+	return prober, addr, nil
+}
@@ -0,0 +1,353 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/digineo/go-ping"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddTargetURL(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	pinger, err := ping.New("0.0.0.0", "")
+	require.NoError(err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	defer ln.Close()
+	port := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+
+	m := New(pinger, 10*time.Millisecond, time.Second)
+	defer m.Stop()
+
+	require.NoError(m.AddTargetURL("icmp", "icmp://127.0.0.1", 0))
+	require.NoError(m.AddTargetURL("tcp", "tcp://127.0.0.1:"+port, 0))
+
+	for _, target := range []string{
+		"icmp://127.0.0.1:80",
+		"tcp://127.0.0.1",
+		"udp://127.0.0.1:http",
+		"http://127.0.0.1:80",
+	} {
+		assert.Error(m.AddTargetURL("invalid", target, 0), target)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	metrics := m.Export()
+	for _, key := range []string{"icmp", "tcp"} {
+		require.Contains(metrics, key)
+		assert.NotZero(metrics[key].PacketsSent, key)
+		assert.Zero(metrics[key].PacketsLost, key)
+	}
+	assert.NotContains(metrics, "invalid")
+}
+
+type testResolver struct {
+	addrs []net.IPAddr
+	ttl   time.Duration
+	err   error
+}
+
+func (r *testResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	addrs, _, err := r.LookupIPAddrTTL(ctx, host)
+	return addrs, err
+}
+
+func (r *testResolver) LookupIPAddrTTL(context.Context, string) ([]net.IPAddr, time.Duration, error) {
+	return r.addrs, r.ttl, r.err
+}
+
+func TestResolveHost(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	pinger, err := ping.New("0.0.0.0", "")
+	require.NoError(err)
+
+	a := net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}
+	b := net.IPAddr{IP: net.IPv4(127, 0, 0, 2)}
+	c := net.IPAddr{IP: net.IPv4(127, 0, 0, 3)}
+
+	resolver := &testResolver{addrs: []net.IPAddr{a, b}, ttl: time.Hour}
+	m := New(pinger, time.Hour, time.Second)
+	m.Resolver = resolver
+	defer m.Stop()
+
+	// register without starting the background lookups
+	opts, err := m.withDefaults(TargetOptions{})
+	require.NoError(err)
+	h := &hostTarget{status: HostStatus{Host: "example.com"}, opts: opts, keys: make(map[string]net.IPAddr), stop: make(chan struct{})}
+	m.hosts["host"] = h
+	ctx := context.Background()
+
+	assert.Equal(time.Hour, m.resolveHost(ctx, "host", h))
+	require.Contains(m.targets, "host/127.0.0.1")
+	require.Contains(m.targets, "host/127.0.0.2")
+	targetB := m.targets["host/127.0.0.2"]
+
+	// DNS change
+	resolver.addrs = []net.IPAddr{c, b}
+	resolver.ttl = time.Second
+	assert.Equal(minResolveInterval, m.resolveHost(ctx, "host", h))
+	assert.NotContains(m.targets, "host/127.0.0.1")
+	assert.Contains(m.targets, "host/127.0.0.3")
+	assert.Same(targetB, m.targets["host/127.0.0.2"], "unchanged address keeps its target")
+
+	// DNS failure keeps the addresses
+	resolver.err = errors.New("SERVFAIL")
+	m.resolveHost(ctx, "host", h)
+	assert.Len(m.targets, 2)
+	status := m.Hosts()["host"]
+	assert.EqualError(status.Err, "SERVFAIL")
+	assert.Equal([]net.IPAddr{b, c}, status.Addrs)
+	assert.False(status.Resolved.IsZero())
+
+	m.RemoveTarget("host")
+	assert.Empty(m.targets)
+	assert.Empty(m.hosts)
+}
+
+func TestAddHost(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	pinger, err := ping.New("0.0.0.0", "")
+	require.NoError(err)
+
+	m := New(pinger, 10*time.Millisecond, time.Second)
+	defer m.Stop()
+
+	require.NoError(m.AddHost("lo", "127.0.0.1"))
+	key := AddressKey("lo", net.IPAddr{IP: net.IPv4(127, 0, 0, 1)})
+
+	assert.Eventually(func() bool {
+		metrics := m.Export()[key]
+		return metrics != nil && metrics.PacketsSent > 0
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(m.Hosts()["lo"].Err)
+
+	host, addr, ok := m.HostOf(key)
+	assert.True(ok)
+	assert.Equal("lo", host)
+	assert.Equal("127.0.0.1", addr.String())
+	_, _, ok = m.HostOf("lo")
+	assert.False(ok)
+}
+
+type testProber struct {
+	fail atomic.Bool
+}
+
+func (p *testProber) PingContext(context.Context, *net.IPAddr) (time.Duration, error) {
+	if p.fail.Load() {
+		return 0, errors.New("i/o timeout")
+	}
+	return time.Millisecond, nil
+}
+
+func TestStateMachine(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+
+	s := newStateMachine(2, 3)
+	for i, tc := range []struct {
+		lost    bool
+		state   State
+		changed bool
+	}{
+		{false, StateUnknown, false},
+		{false, StateUnknown, false},
+		{false, StateUp, true},
+		{true, StateUp, false},
+		{false, StateUp, false},
+		{true, StateUp, false},
+		{true, StateDown, true},
+		{false, StateDown, false},
+		{true, StateDown, false},
+		{false, StateDown, false},
+		{false, StateDown, false},
+		{false, StateUp, true},
+	} {
+		_, to, changed := s.update(tc.lost, now.Add(time.Duration(i)*time.Second))
+		assert.Equal(tc.state, to, "result %d", i)
+		assert.Equal(tc.changed, changed, "result %d", i)
+	}
+	assert.Equal(TargetState{State: StateUp, Since: now.Add(11 * time.Second)}, s.get())
+}
+
+func TestSubscribe(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	pinger, err := ping.New("0.0.0.0", "")
+	require.NoError(err)
+
+	m := New(pinger, 5*time.Millisecond, time.Second)
+	m.DownAfter = 2
+	m.UpAfter = 2
+	var changes atomic.Int32
+	m.OnStateChange = func(StateEvent) { changes.Add(1) }
+
+	events, cancel := m.Subscribe()
+	defer cancel()
+
+	prober := &testProber{}
+	addr := net.IPAddr{IP: net.IPv4(192, 0, 2, 1)}
+	require.NoError(m.AddTargetWithProber("test", addr, prober, 0))
+
+	next := func() StateEvent {
+		select {
+		case ev := <-events:
+			return ev
+		case <-time.After(time.Second):
+			t.Fatal("no state event")
+		}
+		return StateEvent{}
+	}
+
+	ev := next()
+	assert.Equal("test", ev.Key)
+	assert.Equal(addr, ev.Addr)
+	assert.Equal(StateUnknown, ev.From)
+	assert.Equal(StateUp, ev.To)
+
+	prober.fail.Store(true)
+	ev = next()
+	assert.Equal(StateUp, ev.From)
+	assert.Equal(StateDown, ev.To)
+	assert.Equal(TargetState{State: StateDown, Since: ev.Time}, m.States()["test"])
+
+	prober.fail.Store(false)
+	assert.Equal(StateUp, next().To)
+	assert.EqualValues(3, changes.Load())
+
+	m.Stop()
+	_, ok := <-events
+	assert.False(ok, "channel closed")
+}
+
+func TestPersist(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "monitor.json")
+	a := net.IPAddr{IP: net.IPv4(192, 0, 2, 1)}
+	b := net.IPAddr{IP: net.IPv4(192, 0, 2, 2)}
+	prober := &testProber{}
+
+	pinger, err := ping.New("0.0.0.0", "")
+	require.NoError(err)
+	m := New(pinger, 5*time.Millisecond, time.Second)
+	m.UpAfter = 1
+	require.NoError(m.Persist(path, time.Hour))
+	require.NoError(m.AddTargetWithProber("a", a, prober, 0))
+	require.NoError(m.AddTargetWithProber("b", b, prober, 0))
+
+	assert.Eventually(func() bool {
+		return m.States()["a"].State == StateUp
+	}, time.Second, 5*time.Millisecond)
+	m.Stop()
+
+	// restore into a new monitor, where b has a new address
+	pinger, err = ping.New("0.0.0.0", "")
+	require.NoError(err)
+	m = New(pinger, time.Hour, time.Second)
+	defer m.Stop()
+	require.NoError(m.AddTargetWithProber("a", a, prober, 0))
+	require.NoError(m.Persist(path, time.Hour))
+	require.NoError(m.AddTargetWithProber("b", net.IPAddr{IP: net.IPv4(192, 0, 2, 3)}, prober, 0))
+
+	metrics := m.Export()
+	require.Contains(metrics, "a")
+	assert.NotZero(metrics["a"].PacketsSent)
+	assert.NotContains(metrics, "b")
+	assert.Equal(StateUp, m.States()["a"].State)
+	assert.Equal(StateUnknown, m.States()["b"].State)
+
+	assert.EqualError(m.Restore(strings.NewReader(`{"version":2}`)), "unsupported snapshot version 2")
+	assert.NoError(m.RestoreFile(filepath.Join(t.TempDir(), "missing.json")))
+}
+
+// flakyProber fails the first ping.
+type flakyProber struct {
+	calls atomic.Int32
+}
+
+func (p *flakyProber) PingContext(context.Context, *net.IPAddr) (time.Duration, error) {
+	if p.calls.Add(1) == 1 {
+		return 0, errors.New("i/o timeout")
+	}
+	return time.Millisecond, nil
+}
+
+func TestAddTargetWithOptions(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	pinger, err := ping.New("0.0.0.0", "")
+	require.NoError(err)
+
+	m := New(pinger, time.Hour, time.Second)
+	defer m.Stop()
+
+	lo := net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}
+	labels := map[string]string{"role": "core"}
+	require.NoError(m.AddTargetWithOptions("icmp", lo, TargetOptions{
+		Interval:    5 * time.Millisecond,
+		HistorySize: 3,
+		PayloadSize: 100,
+		TTL:         16,
+		DSCP:        46,
+		Labels:      labels,
+	}))
+	labels["role"] = "edge"
+	require.NoError(m.AddTargetWithOptions("flaky", lo, TargetOptions{
+		Interval: 5 * time.Millisecond,
+		Timeout:  2 * time.Millisecond,
+		Retries:  1,
+		Prober:   &flakyProber{},
+	}))
+	require.NoError(m.AddHostWithOptions("host", "127.0.0.1", TargetOptions{
+		Interval: 5 * time.Millisecond,
+		Labels:   map[string]string{"role": "host"},
+	}))
+	require.NoError(m.AddTargetWithOptions("slow", lo, TargetOptions{}))
+
+	assert.Error(m.AddTargetWithOptions("tcp", lo, TargetOptions{TTL: 16, Prober: &ping.TCPProber{Port: 22}}))
+	assert.Error(m.AddTargetWithOptions("dscp", lo, TargetOptions{DSCP: 64}))
+	assert.Error(m.AddTargetWithOptions("overlap", lo, TargetOptions{Interval: time.Second, Retries: 1}))
+
+	assert.Eventually(func() bool {
+		metrics := m.Export()
+		return metrics["icmp"] != nil && metrics["icmp"].PacketsSent == 3 &&
+			metrics["flaky"] != nil && metrics["flaky"].PacketsSent >= 3
+	}, time.Second, 5*time.Millisecond)
+
+	metrics := m.Export()
+	assert.Zero(metrics["icmp"].PacketsLost)
+	assert.Zero(metrics["flaky"].PacketsLost, "retried")
+	assert.NotContains(metrics, "slow")
+	assert.Equal(map[string]string{"role": "core"}, m.Labels("icmp"))
+	assert.Nil(m.Labels("flaky"))
+
+	m.Labels("icmp")["role"] = "edge"
+	assert.Equal("core", m.Labels("icmp")["role"])
+
+	key := AddressKey("host", lo)
+	assert.Eventually(func() bool {
+		metrics := m.Export()[key]
+		return metrics != nil && metrics.PacketsSent > 0
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(map[string]string{"role": "host"}, m.Labels(key))
+}
@@ -0,0 +1,93 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net"
+	"time"
+
+	"github.com/digineo/go-ping"
+)
+
+// TargetOptions configure a single target (see AddTargetWithOptions).
+// Zero values fall back to the Monitor's settings.
+type TargetOptions struct {
+	Interval     time.Duration // interval between pings
+	Timeout      time.Duration // timeout of each attempt
+	StartupDelay time.Duration // delay before the first ping
+	HistorySize  int           // number of results to keep
+
+	// Retries is the number of additional attempts before a ping counts
+	// as lost. Timeout * (Retries + 1) must not exceed the Interval.
+	Retries int
+
+	// PayloadSize, TTL and DSCP modify the ICMP echo requests. Zero keeps
+	// the Pinger's payload and the socket's defaults. They are only
+	// supported by a ping.Pinger.
+	PayloadSize int
+	TTL         int
+	DSCP        int // DiffServ code point (0-63)
+
+	// Labels are free-form attributes of the target (see Monitor.Labels).
+	// The map is copied.
+	Labels map[string]string
+
+	// Prober sends the pings. Defaults to the Monitor's Pinger.
+	Prober ping.Prober
+}
+
+// echoProber pings with EchoOptions.
+type echoProber struct {
+	pinger  *ping.Pinger
+	options ping.EchoOptions
+}
+
+func (p *echoProber) PingContext(ctx context.Context, destination *net.IPAddr) (time.Duration, error) {
+	return p.pinger.PingWith(ctx, destination, p.options)
+}
+
+// withDefaults fills in the Monitor's settings, copies the labels and
+// sets up the prober for the echo options.
+func (p *Monitor) withDefaults(opts TargetOptions) (TargetOptions, error) {
+	if opts.Interval <= 0 {
+		opts.Interval = p.interval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = p.timeout
+	}
+	if opts.HistorySize <= 0 {
+		opts.HistorySize = p.HistorySize
+	}
+	if opts.Prober == nil {
+		opts.Prober = p.pinger
+	}
+	opts.Labels = maps.Clone(opts.Labels)
+
+	if opts.Retries < 0 {
+		return opts, errors.New("negative retries")
+	}
+	if opts.Retries > 0 && opts.Timeout*time.Duration(opts.Retries+1) > opts.Interval {
+		return opts, errors.New("timeout * (retries + 1) exceeds the interval")
+	}
+
+	if opts.PayloadSize == 0 && opts.TTL == 0 && opts.DSCP == 0 {
+		return opts, nil
+	}
+	pinger, ok := opts.Prober.(*ping.Pinger)
+	if !ok {
+		return opts, errors.New("payload size, TTL and DSCP require an ICMP prober")
+	}
+	if opts.PayloadSize < 0 || opts.PayloadSize > 0xffff || opts.TTL < 0 || opts.TTL > 255 || opts.DSCP < 0 || opts.DSCP > 63 {
+		return opts, errors.New("payload size, TTL or DSCP out of range")
+	}
+
+	echo := ping.EchoOptions{TTL: opts.TTL, TOS: opts.DSCP << 2}
+	if opts.PayloadSize > 0 {
+		if err := echo.Payload.Resize(uint16(opts.PayloadSize)); err != nil {
+			return opts, err
+		}
+	}
+	opts.Prober = &echoProber{pinger: pinger, options: echo}
+	return opts, nil
+}
@@ -0,0 +1,179 @@
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const defaultDNSTimeout = 2 * time.Second
+
+// DNSResolver is a TTLResolver querying DNS servers directly over UDP for
+// A and AAAA records. Search domains are not applied, host names are
+// treated as fully qualified.
+type DNSResolver struct {
+	// Servers are the DNS servers (host:port) to query in order. Defaults
+	// to the name servers of /etc/resolv.conf.
+	Servers []string
+
+	// Timeout limits each query. Defaults to 2s.
+	Timeout time.Duration
+}
+
+// LookupIPAddr implements ping.Resolver.
+func (r *DNSResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	addrs, _, err := r.LookupIPAddrTTL(ctx, host)
+	return addrs, err
+}
+
+// LookupIPAddrTTL implements TTLResolver. The TTL is the lowest of all
+// records in the answers, including CNAMEs.
+func (r *DNSResolver) LookupIPAddrTTL(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IPAddr{{IP: ip}}, 0, nil
+	}
+
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var addrs []net.IPAddr
+	var ttl uint32
+	var errs []error
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		a, t, err := r.lookup(ctx, name, qtype)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		addrs = append(addrs, a...)
+		if len(a) > 0 && (ttl == 0 || t < ttl) {
+			ttl = t
+		}
+	}
+	if len(errs) == 2 {
+		return nil, 0, errors.Join(errs...)
+	}
+	return addrs, time.Duration(ttl) * time.Second, nil
+}
+
+// lookup asks the servers in order until one answers.
+func (r *DNSResolver) lookup(ctx context.Context, name dnsmessage.Name, qtype dnsmessage.Type) ([]net.IPAddr, uint32, error) {
+	var err error
+	for _, server := range r.servers() {
+		var addrs []net.IPAddr
+		var ttl uint32
+		if addrs, ttl, err = r.query(ctx, server, name, qtype); err == nil {
+			return addrs, ttl, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, 0, err
+}
+
+// query sends a single question to the server.
+func (r *DNSResolver) query(ctx context.Context, server string, name dnsmessage.Name, qtype dnsmessage.Type) ([]net.IPAddr, uint32, error) {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = defaultDNSTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	id := uint16(rand.Uint32())
+	q := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	b, err := q.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err = conn.Write(b); err != nil {
+		return nil, 0, err
+	}
+
+	buf := make([]byte, 1232)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var m dnsmessage.Message
+		if err = m.Unpack(buf[:n]); err != nil || m.ID != id || !m.Response {
+			continue // not our answer
+		}
+		switch {
+		case m.RCode == dnsmessage.RCodeNameError:
+			return nil, 0, nil
+		case m.RCode != dnsmessage.RCodeSuccess:
+			return nil, 0, errors.New("dns query for " + name.String() + " failed: " + m.RCode.String())
+		case m.Truncated:
+			return nil, 0, errors.New("dns answer for " + name.String() + " truncated")
+		}
+		addrs, ttl := answers(&m)
+		return addrs, ttl, nil
+	}
+}
+
+// answers extracts the addresses and the lowest TTL of the answers.
+func answers(m *dnsmessage.Message) ([]net.IPAddr, uint32) {
+	var addrs []net.IPAddr
+	var ttl uint32
+	for i, rr := range m.Answers {
+		if i == 0 || rr.Header.TTL < ttl {
+			ttl = rr.Header.TTL
+		}
+		switch body := rr.Body.(type) {
+		case *dnsmessage.AResource:
+			addrs = append(addrs, net.IPAddr{IP: net.IP(body.A[:])})
+		case *dnsmessage.AAAAResource:
+			addrs = append(addrs, net.IPAddr{IP: net.IP(body.AAAA[:])})
+		}
+	}
+	return addrs, ttl
+}
+
+// servers returns the configured servers or those of /etc/resolv.conf.
+func (r *DNSResolver) servers() []string {
+	if len(r.Servers) > 0 {
+		return r.Servers
+	}
+
+	var servers []string
+	if f, err := os.Open("/etc/resolv.conf"); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) >= 2 && fields[0] == "nameserver" {
+				servers = append(servers, net.JoinHostPort(fields[1], "53"))
+			}
+		}
+	}
+	if len(servers) == 0 {
+		servers = []string{"127.0.0.1:53"}
+	}
+	return servers
+}
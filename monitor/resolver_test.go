@@ -0,0 +1,86 @@
+package monitor
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// serveDNS answers queries for example.com with a CNAME to www.example.com
+// and its addresses, until the connection is closed.
+func serveDNS(conn net.PacketConn) {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		var q dnsmessage.Message
+		if q.Unpack(buf[:n]) != nil || len(q.Questions) != 1 {
+			continue
+		}
+		question := q.Questions[0]
+		m := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: q.ID, Response: true, RecursionAvailable: true},
+			Questions: q.Questions,
+		}
+		if question.Name.String() != "example.com." {
+			m.RCode = dnsmessage.RCodeNameError
+		} else {
+			target := dnsmessage.MustNewName("www.example.com.")
+			m.Answers = append(m.Answers, dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeCNAME, Class: dnsmessage.ClassINET, TTL: 3600},
+				Body:   &dnsmessage.CNAMEResource{CNAME: target},
+			})
+			header := dnsmessage.ResourceHeader{Name: target, Type: question.Type, Class: dnsmessage.ClassINET}
+			switch question.Type {
+			case dnsmessage.TypeA:
+				header.TTL = 300
+				m.Answers = append(m.Answers, dnsmessage.Resource{Header: header, Body: &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}}})
+			case dnsmessage.TypeAAAA:
+				header.TTL = 60
+				m.Answers = append(m.Answers, dnsmessage.Resource{Header: header, Body: &dnsmessage.AAAAResource{AAAA: [16]byte{0x20, 0x01, 0x0d, 0xb8, 15: 1}}})
+			}
+		}
+		if b, err := m.Pack(); err == nil {
+			conn.WriteTo(b, addr)
+		}
+	}
+}
+
+func TestDNSResolver(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(err)
+	defer conn.Close()
+	go serveDNS(conn)
+
+	r := &DNSResolver{Servers: []string{conn.LocalAddr().String()}, Timeout: time.Second}
+	ctx := context.Background()
+
+	addrs, ttl, err := r.LookupIPAddrTTL(ctx, "example.com")
+	require.NoError(err)
+	assert.Equal([]net.IPAddr{{IP: net.IPv4(192, 0, 2, 1).To4()}, {IP: net.ParseIP("2001:db8::1")}}, addrs)
+	assert.Equal(time.Minute, ttl)
+
+	addrs, err = r.LookupIPAddr(ctx, "missing.example.com")
+	assert.NoError(err)
+	assert.Empty(addrs)
+
+	addrs, ttl, err = r.LookupIPAddrTTL(ctx, "192.0.2.2")
+	assert.NoError(err)
+	assert.Len(addrs, 1)
+	assert.Zero(ttl)
+
+	// no server answers
+	conn.Close()
+	_, _, err = (&DNSResolver{Servers: []string{conn.LocalAddr().String()}, Timeout: 100 * time.Millisecond}).LookupIPAddrTTL(ctx, "example.com")
+	assert.Error(err)
+}
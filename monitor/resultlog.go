@@ -0,0 +1,389 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LogFormat is the file format of a ResultLog.
+type LogFormat int
+
+// Supported log formats.
+const (
+	LogJSON LogFormat = iota // JSON Lines
+	LogCSV                   // CSV with header
+)
+
+var csvHeader = []string{"time", "key", "address", "rtt_ms", "outcome", "error"}
+
+// LogRecord is a single entry of a ResultLog.
+type LogRecord struct {
+	Key     string
+	Address string
+	Result  Result
+}
+
+// jsonRecord is the JSON representation of a LogRecord.
+type jsonRecord struct {
+	Time    time.Time `json:"time"`
+	Key     string    `json:"key"`
+	Address string    `json:"address"`
+	RTT     float64   `json:"rtt_ms"`
+	Outcome string    `json:"outcome"`
+	Err     string    `json:"error,omitempty"`
+}
+
+// ResultLog appends every single result to a file. Assign it to
+// Monitor.ResultLog before adding targets.
+type ResultLog struct {
+	// MaxSize and MaxAge limit the size and age of the file. When
+	// exceeded, the file is renamed with its rotation time as suffix and
+	// a new file is started. Zero disables the limit.
+	MaxSize int64
+	MaxAge  time.Duration
+
+	// Compress enables gzip compression of rotated files.
+	Compress bool
+
+	// Logger receives failed compressions. Defaults to slog.Default().
+	Logger *slog.Logger
+
+	path   string
+	format LogFormat
+	file   *os.File
+	size   int64
+	opened time.Time // time of the first record, for MaxAge
+	mtx    sync.Mutex
+	wg     sync.WaitGroup // running compressions
+}
+
+// NewResultLog opens the log file at path for appending.
+func NewResultLog(path string, format LogFormat) (*ResultLog, error) {
+	l := &ResultLog{path: path, format: format}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *ResultLog) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	l.file = f
+	l.size = fi.Size()
+	l.opened = time.Now()
+	if l.size > 0 {
+		// continue the age of the existing file
+		l.opened = fi.ModTime()
+		if t, ok := firstRecordTime(l.path); ok {
+			l.opened = t
+		}
+	}
+
+	if l.format == LogCSV && l.size == 0 {
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write(csvHeader)
+		w.Flush()
+		return l.write(buf.Bytes())
+	}
+	return nil
+}
+
+func (l *ResultLog) write(b []byte) error {
+	n, err := l.file.Write(b)
+	l.size += int64(n)
+	return err
+}
+
+// Write appends a result. If a due rotation fails, the result is appended
+// to the current file and the error is returned.
+func (l *ResultLog) Write(key string, addr net.IPAddr, res Result) error {
+	b, err := l.encode(&LogRecord{Key: key, Address: addr.String(), Result: res})
+	if err != nil {
+		return err
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if l.file == nil {
+		return os.ErrClosed
+	}
+	if (l.MaxSize > 0 && l.size+int64(len(b)) > l.MaxSize) || (l.MaxAge > 0 && time.Since(l.opened) >= l.MaxAge) {
+		if err := l.rotate(); err != nil {
+			if l.file == nil {
+				return err
+			}
+			return errors.Join(fmt.Errorf("rotating result log: %w", err), l.write(b))
+		}
+	}
+	return l.write(b)
+}
+
+func (l *ResultLog) encode(rec *LogRecord) ([]byte, error) {
+	outcome, errText := "ok", ""
+	if rec.Result.Lost {
+		outcome = "lost"
+	}
+	if rec.Result.Err != nil {
+		errText = rec.Result.Err.Error()
+	}
+	rtt := float64(rec.Result.RTT) / float64(time.Millisecond)
+
+	if l.format == LogCSV {
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write([]string{
+			rec.Result.Sent.Format(time.RFC3339Nano),
+			rec.Key,
+			rec.Address,
+			strconv.FormatFloat(rtt, 'f', -1, 64),
+			outcome,
+			errText,
+		})
+		w.Flush()
+		return buf.Bytes(), w.Error()
+	}
+
+	b, err := json.Marshal(&jsonRecord{
+		Time:    rec.Result.Sent,
+		Key:     rec.Key,
+		Address: rec.Address,
+		RTT:     rtt,
+		Outcome: outcome,
+		Err:     errText,
+	})
+	return append(b, '\n'), err
+}
+
+// Rotate starts a new file. If the current file cannot be renamed, the
+// log continues with it.
+func (l *ResultLog) Rotate() error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if l.file == nil {
+		return os.ErrClosed
+	}
+	return l.rotate()
+}
+
+func (l *ResultLog) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	l.file = nil
+
+	rotated := l.path + "." + time.Now().UTC().Format("20060102T150405.000000000Z")
+	if err := os.Rename(l.path, rotated); err != nil {
+		// keep writing to the current file
+		return errors.Join(err, l.open())
+	}
+	if l.Compress {
+		l.wg.Add(1)
+		go func() {
+			defer l.wg.Done()
+			if err := compressFile(rotated); err != nil {
+				l.logger().Warn("compressing result log failed", "path", rotated, "error", err)
+			}
+		}()
+	}
+	return l.open()
+}
+
+// Close closes the file and waits for running compressions.
+func (l *ResultLog) Close() error {
+	l.mtx.Lock()
+	var err error
+	if l.file != nil {
+		err = l.file.Close()
+		l.file = nil
+	}
+	l.mtx.Unlock()
+
+	l.wg.Wait()
+	return err
+}
+
+// compressFile replaces the file at path by a gzipped copy at path.gz.
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path + ".gz")
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err == nil {
+		err = zw.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst.Name())
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (l *ResultLog) logger() *slog.Logger {
+	if l.Logger != nil {
+		return l.Logger
+	}
+	return slog.Default()
+}
+
+// firstRecordTime returns the time of the first record in the log file.
+func firstRecordTime(path string) (time.Time, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer f.Close()
+
+	var first time.Time
+	errStop := errors.New("stop")
+	err = ReadResultLog(f, func(rec *LogRecord) error {
+		first = rec.Result.Sent
+		return errStop
+	})
+	return first, errors.Is(err, errStop)
+}
+
+// ReadResultLog calls fn for each record of a log written by ResultLog.
+// The format is detected, and gzipped logs are decompressed.
+func ReadResultLog(r io.Reader, fn func(*LogRecord) error) error {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		br = bufio.NewReader(zr)
+	}
+
+	first, err := br.Peek(1)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if first[0] == '{' {
+		return readJSONLog(br, fn)
+	}
+	return readCSVLog(br, fn)
+}
+
+func readJSONLog(r io.Reader, fn func(*LogRecord) error) error {
+	dec := json.NewDecoder(r)
+	for {
+		var jr jsonRecord
+		if err := dec.Decode(&jr); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		rec := newLogRecord(jr.Time, jr.Key, jr.Address, jr.RTT, jr.Outcome, jr.Err)
+		if err := fn(&rec); err != nil {
+			return err
+		}
+	}
+}
+
+func readCSVLog(r io.Reader, fn func(*LogRecord) error) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(csvHeader)
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if row[0] == csvHeader[0] {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339Nano, row[0])
+		if err != nil {
+			return err
+		}
+		rtt, err := strconv.ParseFloat(row[3], 64)
+		if err != nil {
+			return fmt.Errorf("invalid rtt %q: %w", row[3], err)
+		}
+
+		rec := newLogRecord(t, row[1], row[2], rtt, row[4], row[5])
+		if err := fn(&rec); err != nil {
+			return err
+		}
+	}
+}
+
+func newLogRecord(t time.Time, key, addr string, rtt float64, outcome, errText string) LogRecord {
+	rec := LogRecord{
+		Key:     key,
+		Address: addr,
+		Result: Result{
+			RTT:  time.Duration(rtt * float64(time.Millisecond)),
+			Lost: outcome == "lost",
+			Sent: t,
+		},
+	}
+	if errText != "" {
+		rec.Result.Err = errors.New(errText)
+	}
+	return rec
+}
+
+// ReplayResultLog reads the log file at path into a History per target
+// key, each with the given capacity.
+func ReplayResultLog(path string, capacity int) (map[string]*History, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	histories := make(map[string]*History)
+	err = ReadResultLog(f, func(rec *LogRecord) error {
+		h := histories[rec.Key]
+		if h == nil {
+			nh := NewHistory(capacity)
+			h = &nh
+			histories[rec.Key] = h
+		}
+		h.Add(rec.Result)
+		return nil
+	})
+	return histories, err
+}
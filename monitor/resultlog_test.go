@@ -0,0 +1,153 @@
+package monitor
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultLog(t *testing.T) {
+	const ms = time.Millisecond
+	addr := net.IPAddr{IP: net.IPv4(192, 0, 2, 1)}
+	sent := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	results := []Result{
+		{RTT: 12500 * time.Microsecond, Sent: sent},
+		{Lost: true, Err: errors.New("i/o timeout"), Sent: sent.Add(time.Second)},
+		{RTT: 20 * ms, Sent: sent.Add(2 * time.Second)},
+	}
+
+	for name, format := range map[string]LogFormat{"results.jsonl": LogJSON, "results.csv": LogCSV} {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			path := filepath.Join(t.TempDir(), name)
+			l, err := NewResultLog(path, format)
+			require.NoError(err)
+			for _, res := range results {
+				require.NoError(l.Write("a", addr, res))
+			}
+			require.NoError(l.Close())
+			assert.ErrorIs(l.Write("a", addr, results[0]), os.ErrClosed)
+
+			f, err := os.Open(path)
+			require.NoError(err)
+			defer f.Close()
+
+			var records []LogRecord
+			require.NoError(ReadResultLog(f, func(rec *LogRecord) error {
+				records = append(records, *rec)
+				return nil
+			}))
+			require.Len(records, 3)
+			for i, rec := range records {
+				assert.Equal("a", rec.Key)
+				assert.Equal("192.0.2.1", rec.Address)
+				assert.Equal(results[i].RTT, rec.Result.RTT)
+				assert.Equal(results[i].Lost, rec.Result.Lost)
+				assert.True(results[i].Sent.Equal(rec.Result.Sent))
+			}
+			assert.EqualError(records[1].Result.Err, "i/o timeout")
+		})
+	}
+}
+
+func TestResultLogRotation(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "results.jsonl")
+	addr := net.IPAddr{IP: net.IPv4(192, 0, 2, 1)}
+	now := time.Now()
+
+	l, err := NewResultLog(path, LogJSON)
+	require.NoError(err)
+	l.MaxSize = 300
+	l.Compress = true
+	for i := 0; i < 5; i++ {
+		require.NoError(l.Write("a", addr, Result{RTT: time.Duration(i+1) * time.Millisecond, Sent: now.Add(time.Duration(i) * time.Second)}))
+	}
+	require.NoError(l.Close())
+
+	rotated, err := filepath.Glob(path + ".*.gz")
+	require.NoError(err)
+	require.NotEmpty(rotated)
+	plain, _ := filepath.Glob(path + ".*Z")
+	assert.Empty(plain, "rotated files are compressed")
+
+	sent := 0
+	for _, file := range append(rotated, path) {
+		histories, err := ReplayResultLog(file, 10)
+		require.NoError(err, file)
+		if h := histories["a"]; assert.NotNil(h, file) {
+			sent += h.Compute().PacketsSent
+		}
+	}
+	assert.Equal(5, sent)
+
+	_, err = ReplayResultLog(filepath.Join(dir, "missing"), 10)
+	assert.Error(err)
+	assert.Error(ReadResultLog(strings.NewReader("time,key\nfoo,bar\n"), func(*LogRecord) error { return nil }))
+}
+
+func TestResultLogRotationFailure(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	addr := net.IPAddr{IP: net.IPv4(192, 0, 2, 1)}
+
+	l, err := NewResultLog(path, LogJSON)
+	require.NoError(err)
+	defer l.Close()
+
+	// the rename fails
+	require.NoError(os.Remove(path))
+	assert.Error(l.Rotate())
+
+	require.NoError(l.Write("a", addr, Result{RTT: time.Millisecond, Sent: time.Now()}))
+
+	// a due rotation fails, the result is written anyway
+	l.MaxSize = 1
+	require.NoError(os.Remove(path))
+	assert.ErrorContains(l.Write("a", addr, Result{RTT: time.Millisecond, Sent: time.Now()}), "rotating result log")
+
+	histories, err := ReplayResultLog(path, 10)
+	require.NoError(err)
+	if h := histories["a"]; assert.NotNil(h) {
+		assert.Equal(1, h.Compute().PacketsSent)
+	}
+}
+
+func TestResultLogReopen(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "results.csv")
+	addr := net.IPAddr{IP: net.IPv4(192, 0, 2, 1)}
+	now := time.Now()
+
+	l, err := NewResultLog(path, LogCSV)
+	require.NoError(err)
+	require.NoError(l.Write("a", addr, Result{RTT: time.Millisecond, Sent: now.Add(-2 * time.Hour)}))
+	require.NoError(l.Close())
+
+	// the age of the file survives reopening
+	l, err = NewResultLog(path, LogCSV)
+	require.NoError(err)
+	l.MaxAge = time.Hour
+	require.NoError(l.Write("a", addr, Result{RTT: time.Millisecond, Sent: now}))
+	require.NoError(l.Close())
+
+	rotated, err := filepath.Glob(path + ".*")
+	require.NoError(err)
+	assert.Len(rotated, 1)
+}
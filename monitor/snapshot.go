@@ -0,0 +1,226 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotVersion is the version of the snapshot format written by Save.
+const snapshotVersion = 1
+
+// snapshot is the persisted state of a Monitor.
+type snapshot struct {
+	Version int                       `json:"version"`
+	Saved   time.Time                 `json:"saved"`
+	Targets map[string]targetSnapshot `json:"targets"`
+}
+
+type targetSnapshot struct {
+	Addr      string           `json:"addr"`
+	State     State            `json:"state"`
+	Since     time.Time        `json:"since"`
+	Losses    int              `json:"losses"`
+	Successes int              `json:"successes"`
+	Results   []resultSnapshot `json:"results"` // oldest first
+}
+
+type resultSnapshot struct {
+	RTT  time.Duration `json:"rtt"`
+	Lost bool          `json:"lost,omitempty"`
+	Sent time.Time     `json:"sent"`
+	Err  string        `json:"err,omitempty"`
+}
+
+// Save writes the histories and states of all targets to w, in a
+// versioned JSON format.
+func (p *Monitor) Save(w io.Writer) error {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	snap := snapshot{
+		Version: snapshotVersion,
+		Saved:   time.Now(),
+		Targets: make(map[string]targetSnapshot, len(p.targets)),
+	}
+	for key, target := range p.targets {
+		snap.Targets[key] = target.snapshot()
+	}
+	return json.NewEncoder(w).Encode(&snap)
+}
+
+// SaveFile writes a snapshot (see Save) to the file at path. The file is
+// replaced atomically.
+func (p *Monitor) SaveFile(path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err = p.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Restore reads a snapshot written by Save. The histories and states are
+// restored for targets added later with the same key and address (or
+// already present, if their history is still empty). Snapshots of other
+// targets are ignored.
+func (p *Monitor) Restore(r io.Reader) error {
+	var snap snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return err
+	}
+	if snap.Version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", snap.Version)
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.restored = snap.Targets
+	for key, target := range p.targets {
+		p.restoreTarget(key, target)
+	}
+	return nil
+}
+
+// RestoreFile restores a snapshot (see Restore) from the file at path.
+// A missing file is not an error.
+func (p *Monitor) RestoreFile(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return p.Restore(f)
+}
+
+// Persist restores the snapshot at path (see RestoreFile), and saves
+// snapshots to it every interval and when the Monitor is stopped.
+func (p *Monitor) Persist(path string, interval time.Duration) error {
+	if err := p.RestoreFile(path); err != nil {
+		return err
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.persistStop != nil {
+		return errors.New("already persisting")
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	p.persistPath = path
+	p.persistStop = stop
+	p.persistDone = done
+
+	go func() {
+		defer close(done)
+
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-tick.C:
+				if err := p.SaveFile(path); err != nil {
+					p.logger().Warn("saving snapshot failed", "path", path, "error", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// stopPersist stops the periodic snapshots and saves a final one.
+func (p *Monitor) stopPersist() {
+	p.mtx.Lock()
+	stop, done, path := p.persistStop, p.persistDone, p.persistPath
+	p.persistStop = nil
+	p.mtx.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+
+	if err := p.SaveFile(path); err != nil {
+		p.logger().Warn("saving snapshot failed", "path", path, "error", err)
+	}
+}
+
+// restoreTarget restores the snapshot of a target, if any. Needs to be
+// locked externally!
+func (p *Monitor) restoreTarget(key string, target *Target) {
+	snap, ok := p.restored[key]
+	if !ok || snap.Addr != target.addr.String() {
+		return
+	}
+	delete(p.restored, key)
+	target.restore(&snap)
+}
+
+func (n *Target) snapshot() targetSnapshot {
+	snap := targetSnapshot{Addr: n.addr.String()}
+
+	n.state.mtx.Lock()
+	snap.State = n.state.current.State
+	snap.Since = n.state.current.Since
+	snap.Losses = n.state.losses
+	snap.Successes = n.state.successes
+	n.state.mtx.Unlock()
+
+	n.history.RLock()
+	n.history.each(time.Time{}, func(res *Result) {
+		rs := resultSnapshot{RTT: res.RTT, Lost: res.Lost, Sent: res.Sent}
+		if res.Err != nil {
+			rs.Err = res.Err.Error()
+		}
+		snap.Results = append(snap.Results, rs)
+	})
+	n.history.RUnlock()
+
+	return snap
+}
+
+// restore restores the results and the state of a snapshot, unless
+// results have been recorded meanwhile.
+func (n *Target) restore(snap *targetSnapshot) {
+	h := n.history
+	h.Lock()
+	defer h.Unlock()
+
+	if h.count > 0 {
+		return
+	}
+	for _, rs := range snap.Results {
+		res := Result{RTT: rs.RTT, Lost: rs.Lost, Sent: rs.Sent}
+		if rs.Err != "" {
+			res.Err = errors.New(rs.Err)
+		}
+		h.add(res)
+	}
+
+	n.state.mtx.Lock()
+	n.state.current = TargetState{State: snap.State, Since: snap.Since}
+	n.state.losses = snap.Losses
+	n.state.successes = snap.Successes
+	n.state.mtx.Unlock()
+}
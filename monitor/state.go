@@ -0,0 +1,169 @@
+package monitor
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// State is the reachability of a target.
+type State int
+
+// States of a target. Targets start in StateUnknown.
+const (
+	StateUnknown State = iota
+	StateUp
+	StateDown
+)
+
+func (s State) String() string {
+	switch s {
+	case StateUp:
+		return "up"
+	case StateDown:
+		return "down"
+	default:
+		return "unknown"
+	}
+}
+
+// TargetState is the current state of a target.
+type TargetState struct {
+	State State
+	Since time.Time // last state change, zero while unknown
+}
+
+// StateEvent describes a state transition of a target.
+type StateEvent struct {
+	Key  string
+	Addr net.IPAddr
+	From State
+	To   State
+	Time time.Time
+}
+
+const (
+	defaultDownAfter = 3
+	defaultUpAfter   = 5
+)
+
+// stateMachine tracks the state of a target. A target goes down after
+// downAfter consecutive losses and up after upAfter consecutive replies.
+type stateMachine struct {
+	downAfter int
+	upAfter   int
+
+	current   TargetState
+	losses    int // consecutive losses
+	successes int // consecutive replies
+	mtx       sync.Mutex
+}
+
+func newStateMachine(downAfter, upAfter int) *stateMachine {
+	return &stateMachine{
+		downAfter: max(downAfter, 1),
+		upAfter:   max(upAfter, 1),
+	}
+}
+
+func (s *stateMachine) get() TargetState {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.current
+}
+
+// update records a result. It returns the previous and the new state, and
+// whether the state has changed.
+func (s *stateMachine) update(lost bool, now time.Time) (from, to State, changed bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	next := s.current.State
+	if lost {
+		s.losses++
+		s.successes = 0
+		if s.losses >= s.downAfter {
+			next = StateDown
+		}
+	} else {
+		s.successes++
+		s.losses = 0
+		if s.successes >= s.upAfter {
+			next = StateUp
+		}
+	}
+
+	prev := s.current.State
+	if next == prev {
+		return prev, next, false
+	}
+	s.current = TargetState{State: next, Since: now}
+	return prev, next, true
+}
+
+// Subscribe returns a channel receiving the state transitions of all
+// targets, and a function to cancel the subscription. Events are dropped
+// if the channel's buffer is full. The channel is closed when the
+// subscription is cancelled or the Monitor is stopped.
+func (p *Monitor) Subscribe() (<-chan StateEvent, func()) {
+	ch := make(chan StateEvent, 64)
+
+	p.subMtx.Lock()
+	if p.subscribers == nil {
+		p.subscribers = make(map[chan StateEvent]struct{})
+	}
+	p.subscribers[ch] = struct{}{}
+	p.subMtx.Unlock()
+
+	cancel := func() {
+		p.subMtx.Lock()
+		if _, ok := p.subscribers[ch]; ok {
+			delete(p.subscribers, ch)
+			close(ch)
+		}
+		p.subMtx.Unlock()
+	}
+	return ch, cancel
+}
+
+// States returns the current state of each monitored target.
+func (p *Monitor) States() map[string]TargetState {
+	m := make(map[string]TargetState)
+
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	for id, target := range p.targets {
+		m[id] = target.State()
+	}
+	return m
+}
+
+// publish passes a state transition to the handler (if any) and the
+// subscribers.
+func (p *Monitor) publish(ev StateEvent, handler func(StateEvent)) {
+	if handler != nil {
+		handler(ev)
+	}
+
+	p.subMtx.Lock()
+	defer p.subMtx.Unlock()
+
+	for ch := range p.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			p.logger().Warn("state event dropped", "target", ev.Key)
+		}
+	}
+}
+
+// closeSubscribers ends all subscriptions.
+func (p *Monitor) closeSubscribers() {
+	p.subMtx.Lock()
+	for ch := range p.subscribers {
+		close(ch)
+	}
+	p.subscribers = nil
+	p.subMtx.Unlock()
+}
@@ -1,6 +1,9 @@
 package monitor
 
 import (
+	"context"
+	"log/slog"
+	"maps"
 	"net"
 	"sync"
 	"time"
@@ -10,27 +13,39 @@ import (
 
 // Target is a unit of work
 type Target struct {
-	pinger   *ping.Pinger
+	prober   ping.Prober
 	addr     net.IPAddr
 	interval time.Duration
 	timeout  time.Duration
+	retries  int
+	labels   map[string]string
 	stop     chan struct{}
-	history  History
+	history  *History
+	onResult func(Result)
+	state    *stateMachine
+	onState  func(StateEvent)
+	logger   *slog.Logger
 	wg       sync.WaitGroup
 }
 
 // newTarget starts a new monitoring goroutine
-func newTarget(interval, timeout, startupDelay time.Duration, historySize int, pinger *ping.Pinger, addr net.IPAddr) (*Target, error) {
+func newTarget(opts *TargetOptions, history *History, state *stateMachine, addr net.IPAddr, onResult func(Result), onState func(StateEvent), logger *slog.Logger) (*Target, error) {
 	n := &Target{
-		pinger:   pinger,
+		prober:   opts.Prober,
 		addr:     addr,
-		interval: interval,
-		timeout:  timeout,
+		interval: opts.Interval,
+		timeout:  opts.Timeout,
+		retries:  opts.Retries,
+		labels:   opts.Labels,
 		stop:     make(chan struct{}),
-		history:  NewHistory(historySize),
+		history:  history,
+		onResult: onResult,
+		state:    state,
+		onState:  onState,
+		logger:   logger,
 	}
 	n.wg.Add(1)
-	go n.run(startupDelay)
+	go n.run(opts.StartupDelay)
 	return n, nil
 }
 
@@ -69,6 +84,51 @@ func (n *Target) Compute(clear bool) *Metrics {
 	return n.history.Compute()
 }
 
+// State returns the reachability of this node.
+func (n *Target) State() TargetState {
+	return n.state.get()
+}
+
+// ComputeWindow returns the computed ping metrics for the results sent
+// within the last d.
+func (n *Target) ComputeWindow(d time.Duration) *Metrics {
+	return n.history.ComputeWindow(d)
+}
+
+// Labels returns a copy of the labels of this node.
+func (n *Target) Labels() map[string]string {
+	return maps.Clone(n.labels)
+}
+
 func (n *Target) ping() {
-	n.history.AddResult(n.pinger.Ping(&n.addr, n.timeout))
+	sent := time.Now()
+	rtt, err := n.attempt()
+	for i := 0; i < n.retries && err != nil; i++ {
+		rtt, err = n.attempt()
+	}
+
+	res := Result{RTT: rtt, Lost: err != nil, Sent: sent, Err: err}
+	n.history.Add(res)
+	if err != nil {
+		n.logger.Debug("ping failed", "error", err)
+	}
+
+	if n.onResult != nil {
+		n.onResult(res)
+	}
+
+	now := time.Now()
+	if from, to, changed := n.state.update(res.Lost, now); changed {
+		n.logger.Info("state changed", "from", from, "to", to)
+		if n.onState != nil {
+			n.onState(StateEvent{Addr: n.addr, From: from, To: to, Time: now})
+		}
+	}
+}
+
+// attempt sends a single ping.
+func (n *Target) attempt() (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), n.timeout)
+	defer cancel()
+	return n.prober.PingContext(ctx, &n.addr)
 }
@@ -0,0 +1,64 @@
+package ping
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// NewInNamespace is New, but opens the sockets inside the network
+// namespace at nsPath (e.g. /var/run/netns/blue or /proc/<pid>/ns/net).
+// Sockets reopened or rebound later are opened in the same namespace.
+//
+// The namespace only matters for opening sockets, so Pingers of
+// different namespaces can be used side by side in a single process.
+func NewInNamespace(nsPath, bind4, bind6 string) (*Pinger, error) {
+	if nsPath == "" {
+		return nil, errors.New("empty namespace path")
+	}
+	return newPinger(nsPath, bind4, bind6)
+}
+
+// inNamespace runs fn on an OS thread switched into the network namespace
+// at path. An empty path runs fn directly.
+func inNamespace(path string, fn func() error) error {
+	if path == "" {
+		return fn()
+	}
+
+	ns, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer ns.Close()
+
+	errc := make(chan error, 1)
+	go func() {
+		// the thread is only unlocked after switching back successfully,
+		// otherwise it terminates with the goroutine
+		runtime.LockOSThread()
+
+		orig, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", unix.Gettid()))
+		if err != nil {
+			errc <- err
+			return
+		}
+		defer orig.Close()
+
+		if err = unix.Setns(int(ns.Fd()), unix.CLONE_NEWNET); err != nil {
+			errc <- os.NewSyscallError("setns", err)
+			return
+		}
+		err = fn()
+
+		if unix.Setns(int(orig.Fd()), unix.CLONE_NEWNET) == nil {
+			runtime.UnlockOSThread()
+		}
+		errc <- err
+	}()
+
+	return <-errc
+}
@@ -0,0 +1,69 @@
+package ping
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testNamespace creates a network namespace with the given address on
+// its loopback interface. It returns the path of the namespace and a
+// function to run ip commands inside.
+func testNamespace(t *testing.T, name, addr string) (string, func(args ...string) error) {
+	run := func(args ...string) error {
+		out, err := exec.Command("ip", args...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("ip %v: %w: %s", args, err, out)
+		}
+		return nil
+	}
+	if err := run("netns", "add", name); err != nil {
+		t.Skipf("unable to create network namespace: %v", err)
+	}
+	t.Cleanup(func() { run("netns", "del", name) })
+
+	ip := func(args ...string) error {
+		return run(append([]string{"-n", name}, args...)...)
+	}
+	require.NoError(t, ip("link", "set", "lo", "up"))
+	require.NoError(t, ip("addr", "add", addr+"/32", "dev", "lo"))
+
+	return "/var/run/netns/" + name, ip
+}
+
+func TestNewInNamespace(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	const addr = "198.51.100.8"
+	nsPath, _ := testNamespace(t, "goping-new", addr)
+
+	// the address only exists inside the namespace
+	_, err := New(addr, "")
+	require.Error(err)
+
+	pinger, err := NewInNamespace(nsPath, addr, "")
+	require.NoError(err)
+	defer pinger.Close()
+
+	destination := &net.IPAddr{IP: net.ParseIP(addr)}
+	_, err = pinger.Ping(destination, time.Second)
+	assert.NoError(err)
+
+	// reopened sockets stay in the namespace
+	require.NoError(pinger.Rebind())
+	_, err = pinger.Ping(destination, time.Second)
+	assert.NoError(err)
+
+	_, err = pinger.PingOptions(context.Background(), destination, IPOptions{RecordRoute: true})
+	assert.NoError(err)
+
+	_, err = NewInNamespace("/nonexistent", addr, "")
+	assert.Error(err)
+}
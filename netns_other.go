@@ -0,0 +1,19 @@
+//go:build !linux
+
+package ping
+
+import "errors"
+
+var errNamespaces = errors.New("network namespaces are not supported on this platform")
+
+// NewInNamespace is only supported on Linux.
+func NewInNamespace(nsPath, bind4, bind6 string) (*Pinger, error) {
+	return nil, errNamespaces
+}
+
+func inNamespace(path string, fn func() error) error {
+	if path == "" {
+		return fn()
+	}
+	return errNamespaces
+}
@@ -0,0 +1,298 @@
+package ping
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// IPv4 option types (RFC 791)
+const (
+	optEndOfList   = 0
+	optNoOperation = 1
+	optRecordRoute = 7
+	optTimestamp   = 68
+
+	maxOptionsLen = 40
+)
+
+// TimestampOption selects the variant of the IPv4 Timestamp option.
+type TimestampOption int
+
+const (
+	NoTimestamp         TimestampOption = iota // don't include a Timestamp option
+	TimestampOnly                              // record timestamps only (ping -T tsonly)
+	TimestampAndAddress                        // record addresses and timestamps (ping -T tsandaddr)
+)
+
+// IPOptions selects the IPv4 options to include in an echo request. At
+// most one of them can be used, since each occupies (almost) the entire
+// option space of 40 bytes.
+type IPOptions struct {
+	RecordRoute bool            // include a Record Route option (ping -R)
+	Timestamp   TimestampOption // include a Timestamp option (ping -T)
+}
+
+// marshal builds the option bytes, padded to a multiple of 4 bytes.
+func (o IPOptions) marshal() ([]byte, error) {
+	var b []byte
+	switch {
+	case o.RecordRoute && o.Timestamp != NoTimestamp:
+		return nil, errors.New("cannot combine Record Route and Timestamp option")
+	case o.RecordRoute:
+		// 9 slots for addresses
+		b = make([]byte, 3+9*4, maxOptionsLen)
+		b[0], b[1], b[2] = optRecordRoute, byte(len(b)), 4
+	case o.Timestamp == TimestampOnly:
+		// 9 slots for timestamps
+		b = make([]byte, 4+9*4, maxOptionsLen)
+		b[0], b[1], b[2], b[3] = optTimestamp, byte(len(b)), 5, 0
+	case o.Timestamp == TimestampAndAddress:
+		// 4 slots for address/timestamp pairs
+		b = make([]byte, 4+4*8, maxOptionsLen)
+		b[0], b[1], b[2], b[3] = optTimestamp, byte(len(b)), 5, 1
+	default:
+		return nil, errors.New("no IP option selected")
+	}
+
+	for len(b)%4 != 0 {
+		b = append(b, optEndOfList)
+	}
+	return b, nil
+}
+
+// RecordedTimestamp is an entry of the IPv4 Timestamp option.
+type RecordedTimestamp struct {
+	Addr     net.IP    // recording host, nil for TimestampOnly
+	Time     time.Time // only valid if Standard is true
+	Standard bool      // whether the timestamp is in milliseconds since midnight UT
+}
+
+// OptionsReply is the result of PingOptions.
+type OptionsReply struct {
+	RTT        time.Duration       // round trip time
+	Route      []net.IP            // addresses recorded by the Record Route option
+	Timestamps []RecordedTimestamp // entries recorded by the Timestamp option
+	Overflow   int                 // number of hosts unable to register a timestamp
+}
+
+// parseOptions extracts Record Route and Timestamp options from the
+// options of an IPv4 header.
+func parseOptions(b []byte, ref time.Time) *OptionsReply {
+	reply := &OptionsReply{}
+
+	for len(b) > 0 {
+		switch b[0] {
+		case optEndOfList:
+			return reply
+		case optNoOperation:
+			b = b[1:]
+			continue
+		}
+		if len(b) < 2 || int(b[1]) < 2 || int(b[1]) > len(b) {
+			return reply // malformed
+		}
+		opt := b[:b[1]]
+		b = b[b[1]:]
+
+		switch opt[0] {
+		case optRecordRoute:
+			if len(opt) < 3 {
+				continue
+			}
+			// the pointer is 1-based and points to the next free slot
+			for i := 3; i+4 <= len(opt) && i+1 < int(opt[2]); i += 4 {
+				reply.Route = append(reply.Route, net.IP(append([]byte(nil), opt[i:i+4]...)))
+			}
+		case optTimestamp:
+			if len(opt) < 4 {
+				continue
+			}
+			reply.Overflow = int(opt[3] >> 4)
+			withAddr := opt[3]&0x0f != 0
+			size := 4
+			if withAddr {
+				size = 8
+			}
+			for i := 4; i+size <= len(opt) && i+1 < int(opt[2]); i += size {
+				var ts RecordedTimestamp
+				entry := opt[i : i+size]
+				if withAddr {
+					ts.Addr = net.IP(append([]byte(nil), entry[:4]...))
+					entry = entry[4:]
+				}
+				raw := binary.BigEndian.Uint32(entry)
+				if ts.Standard = raw&nonStandardTimestamp == 0; ts.Standard {
+					ts.Time = fromMidnight(raw, ref)
+				}
+				reply.Timestamps = append(reply.Timestamps, ts)
+			}
+		}
+	}
+	return reply
+}
+
+// An optionsRequest is a simpleRequest sent with IPv4 options. It is
+// finished by the receiver of the raw IPv4 socket, which has access to
+// the IP header of the reply.
+type optionsRequest struct {
+	simpleRequest
+	options []byte       // options to send
+	header  *ipv4.Header // header of the reply
+}
+
+// PingOptions sends a single ICMP echo request with the given IPv4
+// options and waits for an answer until the context is done. The options
+// are evaluated from the IP header of the reply, so hops only appear
+// if they honour the options.
+//
+// This needs an additional raw socket, which is opened on first use.
+func (pinger *Pinger) PingOptions(ctx context.Context, destination *net.IPAddr, opts IPOptions) (*OptionsReply, error) {
+	if destination.IP.To4() == nil {
+		return nil, errUnsupported
+	}
+
+	options, err := opts.marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	// open the socket before starting the measurement
+	if _, err = pinger.rawConn4(); err != nil {
+		return nil, err
+	}
+
+	req := optionsRequest{options: options}
+	key, err := pinger.sendRequest(destination, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = pinger.await(ctx, key, &req.simpleRequest); err != nil {
+		return nil, err
+	}
+	if req.header == nil || req.tFinish == nil {
+		return nil, errors.New("invalid echo reply")
+	}
+
+	reply := parseOptions(req.header.Options, req.tStart)
+	reply.RTT = req.tFinish.Sub(req.tStart)
+	return reply, nil
+}
+
+// rawConn4 returns the raw IPv4 socket, opening it if necessary.
+func (pinger *Pinger) rawConn4() (*ipv4.RawConn, error) {
+	pinger.raw4Mtx.Lock()
+	defer pinger.raw4Mtx.Unlock()
+
+	if pinger.raw4 != nil {
+		return pinger.raw4, nil
+	}
+	if pinger.bind4 == "" {
+		return nil, errUnsupported
+	}
+	if pinger.closed {
+		return nil, errClosed
+	}
+
+	var c net.PacketConn
+	err := inNamespace(pinger.netns, func() (err error) {
+		c, err = net.ListenPacket("ip4:icmp", pinger.bind4)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	raw, err := ipv4.NewRawConn(c)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	pinger.raw4 = raw
+	pinger.wg.Add(1)
+	go pinger.rawReceiver(raw)
+
+	return raw, nil
+}
+
+// writeOptions sends an ICMP message with the given IPv4 options.
+func (pinger *Pinger) writeOptions(destination *net.IPAddr, wb, options []byte) error {
+	raw, err := pinger.rawConn4()
+	if err != nil {
+		return err
+	}
+
+	hdr := ipv4.Header{
+		Version:  ipv4.Version,
+		Len:      ipv4.HeaderLen + len(options),
+		TotalLen: ipv4.HeaderLen + len(options) + len(wb),
+		TTL:      64,
+		Protocol: ProtocolICMP,
+		Dst:      destination.IP.To4(),
+		Options:  options,
+	}
+	return raw.WriteTo(&hdr, wb, nil)
+}
+
+// rawReceiver listens on the raw IPv4 socket and finishes running
+// optionsRequests. Everything else is left to the regular receiver.
+func (pinger *Pinger) rawReceiver(raw *ipv4.RawConn) {
+	rb := make([]byte, 1500)
+
+	for {
+		hdr, payload, _, err := raw.ReadFrom(rb)
+		if err != nil {
+			if netErr, ok := err.(net.Error); !ok || !netErr.Temporary() { //nolint:staticcheck
+				break // socket gone
+			}
+			continue
+		}
+		pinger.receiveOptions(hdr, payload, time.Now())
+	}
+
+	// reopen on next use, unless closed
+	pinger.raw4Mtx.Lock()
+	if pinger.raw4 == raw && !pinger.closed {
+		raw.Close()
+		pinger.raw4 = nil
+	}
+	pinger.raw4Mtx.Unlock()
+
+	pinger.wg.Done()
+}
+
+// receiveOptions finishes the optionsRequest answered by the given packet.
+func (pinger *Pinger) receiveOptions(hdr *ipv4.Header, payload []byte, t time.Time) {
+	m, err := icmp.ParseMessage(ProtocolICMP, payload)
+	if err != nil || m.Type != ipv4.ICMPTypeEchoReply {
+		return
+	}
+	key, ok := keyOf(m.Body)
+	if !ok {
+		return
+	}
+
+	pinger.mtx.Lock()
+	req, ok := pinger.requests[key].(*optionsRequest)
+	if ok {
+		if err = pinger.verify(req, hdr.Src, nil, nil); err != nil {
+			// possibly spoofed, wait for the genuine reply
+			pinger.mtx.Unlock()
+			pinger.logger().Debug("ignoring reply", "id", key.idseq>>16, "seq", key.idseq&0xffff, "error", err)
+			return
+		}
+		delete(pinger.requests, key)
+	}
+	pinger.mtx.Unlock()
+
+	if ok {
+		req.header = hdr
+		req.handleReply(nil, hdr.Src, &t)
+	}
+}
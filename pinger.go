@@ -1,11 +1,16 @@
 package ping
 
 import (
+	"errors"
+	"fmt"
+	"log/slog"
 	"net"
 	"os"
 	"sync"
+	"time"
 
 	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
 )
 
 const (
@@ -23,78 +28,314 @@ var sequence uint32
 
 // Pinger is a instance for ICMP echo requests
 type Pinger struct {
-	LogUnexpectedPackets bool // increases log verbosity
+	LogUnexpectedPackets bool         // increases log verbosity
+	Logger               *slog.Logger // defaults to slog.Default()
 	Id                   uint16
 	SequenceCounter      *uint32
 
+	// AcceptAnySource accepts replies from other addresses than the
+	// destination, e.g. for anycast destinations. Otherwise such replies
+	// are ignored, and the request times out unless the destination
+	// answers. ICMP error messages quoting requests to other destinations
+	// are always ignored.
+	AcceptAnySource bool
+
+	// Reopen makes the Pinger reopen its sockets with the original bind
+	// addresses and socket options when they fail. Otherwise a failed
+	// Pinger stays failed until it is closed.
+	Reopen        bool
+	ReopenBackoff Backoff // delay between attempts, defaults to DefaultReopenBackoff
+
 	payload   Payload
 	payloadMu sync.RWMutex
 
-	requests map[uint32]request // currently running requests
-	mtx      sync.RWMutex       // lock for the requests map
+	probeSequence uint32 // sequence counter of extended echo requests
+
+	requests map[requestKey]request // currently running requests
+	mtx      sync.RWMutex           // lock for the requests map
 	conn4    net.PacketConn
 	conn6    net.PacketConn
-	write4   sync.Mutex // lock for conn4.WriteTo
-	write6   sync.Mutex // lock for conn6.WriteTo
+	connMtx  sync.RWMutex // lock for conn4, conn6, err and mark
+	write4   sync.Mutex   // lock for conn4.WriteTo
+	write6   sync.Mutex   // lock for conn6.WriteTo
 	wg       sync.WaitGroup
+
+	err    error         // reason of failure, nil if the sockets work
+	health chan error    // failures and recoveries, see Health
+	done   chan struct{} // closed by Close
+	mark   *uint         // SO_MARK to restore when reopening
+
+	bind4   string        // IPv4 bind address
+	bind6   string        // IPv6 bind address
+	netns   string        // path of the network namespace, if any
+	raw4    *ipv4.RawConn // opened on demand for IPv4 options
+	raw4Mtx sync.Mutex    // lock for raw4 and closed
+	closed  bool          // whether Close was called
 }
 
+// DefaultReopenBackoff is the delay between attempts to reopen failed
+// sockets, unless Pinger.ReopenBackoff is set.
+var DefaultReopenBackoff = ExponentialBackoff(time.Second, 2, time.Minute)
+
 // New creates a new Pinger. This will open the raw socket and start the
 // receiving logic. You'll need to call Close() to cleanup.
 func New(bind4, bind6 string) (*Pinger, error) {
-	// open sockets
-	conn4, err := connectICMP("ip4:icmp", bind4)
-	if err != nil {
+	return newPinger("", bind4, bind6)
+}
+
+func newPinger(netns, bind4, bind6 string) (*Pinger, error) {
+	pinger := Pinger{
+		netns:           netns,
+		bind4:           bind4,
+		bind6:           bind6,
+		Id:              uint16(os.Getpid()),
+		SequenceCounter: &sequence,
+		requests:        make(map[requestKey]request),
+		health:          make(chan error, 16),
+		done:            make(chan struct{}),
+	}
+	pinger.SetPayloadSize(56)
+
+	if err := pinger.open(); err != nil {
 		return nil, err
 	}
+	return &pinger, nil
+}
 
-	conn6, err := connectICMP("ip6:ipv6-icmp", bind6)
-	if err != nil {
-		if conn4 != nil {
+// open opens the sockets and starts the receivers. The Pinger is healthy
+// afterwards.
+func (pinger *Pinger) open() error {
+	var conn4, conn6 net.PacketConn
+	err := inNamespace(pinger.netns, func() (err error) {
+		conn4, err = connectICMP("ip4:icmp", pinger.bind4)
+		if err != nil {
+			return err
+		}
+
+		conn6, err = connectICMP("ip6:ipv6-icmp", pinger.bind6)
+		if err != nil && conn4 != nil {
 			conn4.Close()
 		}
-		return nil, err
+		return err
+	})
+	if err != nil {
+		return err
 	}
 
 	if conn4 == nil && conn6 == nil {
-		return nil, errNotBound
+		return errNotBound
 	}
 
-	pinger := Pinger{
-		conn4:           conn4,
-		conn6:           conn6,
-		Id:              uint16(os.Getpid()),
-		SequenceCounter: &sequence,
-		requests:        make(map[uint32]request),
+	pinger.connMtx.Lock()
+	defer pinger.connMtx.Unlock()
+
+	select {
+	case <-pinger.done:
+		// closed in the meantime
+		pinger.close(conn4)
+		pinger.close(conn6)
+		return errClosed
+	default:
 	}
-	pinger.SetPayloadSize(56)
+
+	if pinger.mark != nil {
+		for _, conn := range []net.PacketConn{conn4, conn6} {
+			if err = setMark(conn, *pinger.mark); err != nil {
+				pinger.close(conn4)
+				pinger.close(conn6)
+				return err
+			}
+		}
+	}
+
+	// replace the previous sockets, their receivers stop silently
+	pinger.close(pinger.conn4)
+	pinger.close(pinger.conn6)
+	pinger.conn4 = conn4
+	pinger.conn6 = conn6
+	pinger.err = nil
+
+	// the raw socket is reopened on next use
+	pinger.raw4Mtx.Lock()
+	if pinger.raw4 != nil {
+		pinger.raw4.Close()
+		pinger.raw4 = nil
+	}
+	pinger.raw4Mtx.Unlock()
 
 	if conn4 != nil {
 		pinger.wg.Add(1)
-		go pinger.receiver(ProtocolICMP, pinger.conn4)
+		go pinger.receiver(ProtocolICMP, conn4)
 	}
 	if conn6 != nil {
 		pinger.wg.Add(1)
-		go pinger.receiver(ProtocolICMPv6, pinger.conn6)
+		go pinger.receiver(ProtocolICMPv6, conn6)
 	}
 
-	return &pinger, nil
+	return nil
 }
 
-// Close will close the ICMP socket.
+// Close will close the ICMP socket. Afterwards, Err and Health report an
+// error matching net.ErrClosed. Subsequent calls have no effect.
 func (pinger *Pinger) Close() {
+	pinger.raw4Mtx.Lock()
+	if pinger.closed {
+		pinger.raw4Mtx.Unlock()
+		return
+	}
+	pinger.closed = true
+	if pinger.raw4 != nil {
+		pinger.raw4.Close()
+	}
+	pinger.raw4Mtx.Unlock()
+
+	pinger.connMtx.Lock()
+	close(pinger.done)
 	pinger.close(pinger.conn4)
 	pinger.close(pinger.conn6)
+	pinger.err = errClosed
+	pinger.notify(errClosed)
+	pinger.connMtx.Unlock()
+
 	pinger.wg.Wait()
+	pinger.failRequests(errClosed)
+}
+
+// Err returns the reason why the sockets of the Pinger failed, or nil if
+// they are working. Pings return this error immediately.
+func (pinger *Pinger) Err() error {
+	pinger.connMtx.RLock()
+	defer pinger.connMtx.RUnlock()
+	return pinger.err
+}
+
+// Health returns a channel receiving the error when the sockets fail,
+// errors of failed attempts to reopen them, and nil once they were
+// reopened. Events are dropped if the channel is not drained.
+func (pinger *Pinger) Health() <-chan error {
+	return pinger.health
+}
+
+// notify sends an event to the health channel without blocking.
+func (pinger *Pinger) notify(err error) {
+	select {
+	case pinger.health <- err:
+	default:
+	}
+}
+
+// fail marks the Pinger as failed after receiving from a socket failed.
+// The remaining sockets are closed, running requests are finished with
+// the error, and reopening the sockets is started if configured.
+func (pinger *Pinger) fail(err error) {
+	pinger.connMtx.Lock()
+	defer pinger.connMtx.Unlock()
+
+	select {
+	case <-pinger.done:
+		return // closed in the meantime
+	default:
+	}
+	if pinger.err != nil {
+		return // already failed
+	}
+
+	pinger.logger().Error("socket failed", "error", err, "reopen", pinger.Reopen)
+	pinger.err = fmt.Errorf("socket failed: %w", err)
+	pinger.close(pinger.conn4)
+	pinger.close(pinger.conn6)
+	pinger.failRequests(pinger.err)
+	pinger.notify(pinger.err)
+
+	if pinger.Reopen {
+		pinger.wg.Add(1)
+		go pinger.reopen()
+	}
+}
+
+// reopen tries to reopen the sockets until it succeeds or the Pinger is
+// closed.
+func (pinger *Pinger) reopen() {
+	defer pinger.wg.Done()
+
+	backoff := pinger.ReopenBackoff
+	if backoff == nil {
+		backoff = DefaultReopenBackoff
+	}
+
+	timer := time.NewTimer(backoff(1))
+	defer timer.Stop()
+
+	for retry := 1; ; retry++ {
+		select {
+		case <-pinger.done:
+			return
+		case <-timer.C:
+		}
+		if pinger.Err() == nil {
+			return // rebound in the meantime
+		}
+
+		err := pinger.open()
+		if err == nil {
+			pinger.logger().Info("sockets reopened", "attempts", retry)
+			pinger.notify(nil)
+			return
+		}
+		if errors.Is(err, errClosed) {
+			return
+		}
+
+		pinger.logger().Warn("reopening sockets failed", "error", err, "attempts", retry)
+		pinger.notify(err)
+		timer.Reset(backoff(retry + 1))
+	}
+}
+
+// Rebind opens the sockets again with the original bind addresses and
+// socket options and replaces the current ones, e.g. after the bind
+// address was removed and re-added to an interface. If that fails, the
+// Pinger is failed (see Err) and the error is returned.
+func (pinger *Pinger) Rebind() error {
+	failed := pinger.Err() != nil
+
+	err := pinger.open()
+	if err == nil {
+		if failed {
+			pinger.notify(nil)
+		}
+		return nil
+	}
+	if !errors.Is(err, errClosed) {
+		pinger.fail(err)
+	}
+	return err
+}
+
+// failRequests finishes all running requests with the given error.
+func (pinger *Pinger) failRequests(err error) {
+	pinger.mtx.Lock()
+	defer pinger.mtx.Unlock()
+
+	for key, req := range pinger.requests {
+		if _, ok := req.(*multiRequest); !ok {
+			delete(pinger.requests, key)
+		}
+		req.handleReply(err, nil, nil)
+	}
 }
 
 // connectICMP opens a new ICMP connection, if network and address are not empty.
-func connectICMP(network, address string) (*icmp.PacketConn, error) {
+func connectICMP(network, address string) (net.PacketConn, error) {
 	if network == "" || address == "" {
-		return nil, nil
+		return nil, nil // avoid a non-nil interface holding a nil pointer
 	}
 
-	return icmp.ListenPacket(network, address)
+	conn, err := icmp.ListenPacket(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
 }
 
 func (pinger *Pinger) close(conn net.PacketConn) {
@@ -103,9 +344,19 @@ func (pinger *Pinger) close(conn net.PacketConn) {
 	}
 }
 
-func (pinger *Pinger) removeRequest(idseq uint32) {
+func (pinger *Pinger) logger() *slog.Logger {
+	if pinger.Logger != nil {
+		return pinger.Logger
+	}
+	if logger := defaultLogger.Load(); logger != nil {
+		return logger
+	}
+	return slog.Default()
+}
+
+func (pinger *Pinger) removeRequest(key requestKey) {
 	pinger.mtx.Lock()
-	delete(pinger.requests, idseq)
+	delete(pinger.requests, key)
 	pinger.mtx.Unlock()
 }
 
@@ -115,8 +366,12 @@ func (pinger *Pinger) removeRequest(idseq uint32) {
 // The default payload size is 56, resulting in 64 bytes for the ICMP packet.
 func (pinger *Pinger) SetPayloadSize(size uint16) {
 	pinger.payloadMu.Lock()
-	pinger.payload.Resize(size)
+	err := pinger.payload.Resize(size)
 	pinger.payloadMu.Unlock()
+
+	if err != nil {
+		pinger.logger().Error("resizing payload failed", "error", err)
+	}
 }
 
 // SetPayload allows you to overwrite the current payload with your own data.
@@ -132,3 +387,19 @@ func (pinger *Pinger) PayloadSize() uint16 {
 	defer pinger.payloadMu.RUnlock()
 	return uint16(len(pinger.payload))
 }
+
+// SetMark sets the SO_MARK socket option on the sockets, which is used
+// by Linux for policy routing. The mark is restored when the sockets are
+// reopened.
+func (pinger *Pinger) SetMark(mark uint) error {
+	pinger.connMtx.Lock()
+	defer pinger.connMtx.Unlock()
+
+	for _, conn := range []net.PacketConn{pinger.conn4, pinger.conn6} {
+		if err := setMark(conn, mark); err != nil {
+			return err
+		}
+	}
+	pinger.mark = &mark
+	return nil
+}
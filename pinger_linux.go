@@ -2,6 +2,7 @@ package ping
 
 import (
 	"errors"
+	"net"
 	"os"
 	"reflect"
 	"syscall"
@@ -29,32 +30,18 @@ func getFD(c *icmp.PacketConn) (uintptr, error) {
 	return uintptr(pfd.FieldByName("Sysfd").Int()), nil
 }
 
-func (pinger *Pinger) SetMark(mark uint) error {
-	conn4, ok := pinger.conn4.(*icmp.PacketConn)
-	if !ok {
-		return errors.New("invalid connection type")
-	}
-
-	fd, err := getFD(conn4)
-	if err != nil {
-		return err
-	}
-
-	err = os.NewSyscallError(
-		"setsockopt",
-		syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_MARK, int(mark)),
-	)
-
-	if err != nil {
-		return err
+// setMark sets the SO_MARK socket option, if conn is not nil.
+func setMark(conn net.PacketConn, mark uint) error {
+	if conn == nil {
+		return nil
 	}
 
-	conn6, ok := pinger.conn6.(*icmp.PacketConn)
+	c, ok := conn.(*icmp.PacketConn)
 	if !ok {
 		return errors.New("invalid connection type")
 	}
 
-	fd, err = getFD(conn6)
+	fd, err := getFD(c)
 	if err != nil {
 		return err
 	}
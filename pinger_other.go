@@ -2,8 +2,11 @@
 
 package ping
 
-import "errors"
+import (
+	"errors"
+	"net"
+)
 
-func (pinger *Pinger) SetMark(mark uint) error {
+func setMark(conn net.PacketConn, mark uint) error {
 	return errors.New("setting SO_MARK socket option is not supported on this platform")
 }
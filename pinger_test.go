@@ -1,12 +1,20 @@
 package ping
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"net"
+	"os"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
 )
 
 func TestPinger(t *testing.T) {
@@ -24,3 +32,688 @@ func TestPinger(t *testing.T) {
 		assert.NotZero(rtt, target)
 	}
 }
+
+func TestPingRetry(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	pinger, err := New("0.0.0.0", "")
+	require.NoError(err)
+	defer pinger.Close()
+
+	policy := RetryPolicy{Attempts: 3, Timeout: time.Second}
+	attempts, err := pinger.PingRetry(context.Background(), &net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}, policy)
+	assert.NoError(err)
+	require.Len(attempts, 1)
+	assert.NoError(attempts[0].Err)
+	assert.NotZero(attempts[0].RTT)
+
+	// no reply arrives within a nanosecond, every attempt times out
+	policy = RetryPolicy{Attempts: 3, Timeout: time.Nanosecond, Backoff: FixedBackoff(5 * time.Millisecond)}
+	attempts, err = pinger.PingRetry(context.Background(), &net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}, policy)
+	assert.True(isTimeout(err))
+	require.Len(attempts, 3)
+	for _, a := range attempts {
+		assert.True(isTimeout(a.Err))
+	}
+	assert.True(attempts[1].Start.Sub(attempts[0].Start) >= 5*time.Millisecond)
+}
+
+func TestBackoff(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(time.Second, FixedBackoff(time.Second)(3))
+
+	exp := ExponentialBackoff(100*time.Millisecond, 2, time.Second)
+	assert.Equal(100*time.Millisecond, exp(1))
+	assert.Equal(200*time.Millisecond, exp(2))
+	assert.Equal(800*time.Millisecond, exp(4))
+	assert.Equal(time.Second, exp(5))
+
+	jitter := Jitter(FixedBackoff(time.Second), 0.1)
+	for i := 1; i < 100; i++ {
+		assert.InDelta(time.Second, jitter(i), float64(100*time.Millisecond))
+	}
+}
+
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
+
+func TestPingHedged(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	pinger, err := New("0.0.0.0", "")
+	require.NoError(err)
+	defer pinger.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	res, err := pinger.PingHedged(ctx, &net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}, 3, 200*time.Millisecond)
+	assert.NoError(err)
+	assert.Equal(1, res.Sent)
+	assert.Equal(1, res.Probe)
+	assert.NotZero(res.RTT)
+
+	pinger.mtx.RLock()
+	assert.Empty(pinger.requests)
+	pinger.mtx.RUnlock()
+}
+
+// lossyConn drops the first echo request and answers the others by
+// passing a reply to the Pinger.
+type lossyConn struct {
+	net.PacketConn
+	pinger *Pinger
+	writes int
+}
+
+func (c *lossyConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	c.writes++
+	if c.writes > 1 {
+		msg, err := icmp.ParseMessage(ProtocolICMP, b)
+		if err != nil {
+			return 0, err
+		}
+		msg.Type = ipv4.ICMPTypeEchoReply
+		reply, err := msg.Marshal(nil)
+		if err != nil {
+			return 0, err
+		}
+		go c.pinger.receive(ProtocolICMP, reply, addr.(*net.IPAddr).IP, time.Now())
+	}
+	return len(b), nil
+}
+
+func TestPingHedgedLoss(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var seq uint32
+	pinger := &Pinger{Id: 0x1234, SequenceCounter: &seq, requests: make(map[requestKey]request)}
+	conn := &lossyConn{pinger: pinger}
+	pinger.conn4 = conn
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	res, err := pinger.PingHedged(ctx, &net.IPAddr{IP: net.IPv4(198, 51, 100, 1)}, 3, 20*time.Millisecond)
+	require.NoError(err)
+	assert.Equal(2, res.Sent)
+	assert.Equal(2, res.Probe)
+	assert.NotZero(res.RTT)
+	assert.Equal(2, conn.writes, "the reply cancels the third probe")
+
+	// the first probe is dequeued
+	pinger.mtx.RLock()
+	assert.Empty(pinger.requests)
+	pinger.mtx.RUnlock()
+}
+
+func TestCloseTwice(t *testing.T) {
+	pinger, err := New("0.0.0.0", "")
+	require.NoError(t, err)
+
+	pinger.Close()
+	assert.NotPanics(t, pinger.Close)
+
+	_, err = pinger.Ping(&net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}, time.Second)
+	assert.ErrorIs(t, err, net.ErrClosed)
+	assert.ErrorIs(t, pinger.Err(), net.ErrClosed)
+	assert.ErrorIs(t, <-pinger.Health(), net.ErrClosed)
+}
+
+// testLogger records the messages passed by SetLogger.
+type testLogger struct {
+	lines []string
+}
+
+func (l *testLogger) Infof(format string, a ...interface{}) {
+	l.lines = append(l.lines, "INFO "+fmt.Sprintf(format, a...))
+}
+
+func (l *testLogger) Errorf(format string, a ...interface{}) {
+	l.lines = append(l.lines, "ERROR "+fmt.Sprintf(format, a...))
+}
+
+func TestSetLogger(t *testing.T) {
+	assert := assert.New(t)
+
+	pinger := &Pinger{}
+	assert.Equal(slog.Default(), pinger.logger())
+
+	var l testLogger
+	SetLogger(&l)
+	defer SetLogger(nil)
+
+	logger := pinger.logger().With("id", 1)
+	logger.Debug("dropped")
+	logger.Info("request failed", "destination", "192.0.2.1")
+	logger.Error("resizing payload failed", "error", io.EOF)
+	assert.Equal([]string{
+		"INFO msg=\"request failed\" id=1 destination=192.0.2.1",
+		"ERROR msg=\"resizing payload failed\" id=1 error=EOF",
+	}, l.lines)
+
+	pinger.Logger = slog.Default()
+	assert.Equal(slog.Default(), pinger.logger())
+}
+
+func TestReopen(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	pinger, err := New("0.0.0.0", "")
+	require.NoError(err)
+	defer pinger.Close()
+	pinger.Reopen = true
+	pinger.ReopenBackoff = FixedBackoff(10 * time.Millisecond)
+
+	localhost := &net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}
+	pinger.fail(errors.New("network is down"))
+
+	// pings fail immediately
+	_, err = pinger.Ping(localhost, time.Second)
+	assert.EqualError(err, "socket failed: network is down")
+	assert.Equal(err, pinger.Err())
+
+	select {
+	case err = <-pinger.Health():
+		assert.EqualError(err, "socket failed: network is down")
+	case <-time.After(time.Second):
+		t.Fatal("failure not reported")
+	}
+	select {
+	case err = <-pinger.Health():
+		assert.NoError(err)
+	case <-time.After(time.Second):
+		t.Fatal("recovery not reported")
+	}
+
+	assert.NoError(pinger.Err())
+	_, err = pinger.Ping(localhost, time.Second)
+	assert.NoError(err)
+}
+
+func TestPingTimestamp(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	pinger, err := New("0.0.0.0", "::")
+	require.NoError(err)
+	defer pinger.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	reply, err := pinger.PingTimestamp(ctx, &net.IPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(err)
+	assert.NotZero(reply.RTT)
+	assert.True(reply.Standard)
+
+	// same clock, millisecond resolution
+	assert.InDelta(0, reply.Offset(), float64(2*time.Millisecond))
+	assert.InDelta(0, reply.ForwardDelay(), float64(2*time.Millisecond))
+	assert.InDelta(0, reply.ReturnDelay(), float64(2*time.Millisecond))
+
+	_, err = pinger.PingTimestamp(ctx, &net.IPAddr{IP: net.IPv6loopback})
+	assert.Equal(errUnsupported, err)
+}
+
+func TestFromMidnight(t *testing.T) {
+	assert := assert.New(t)
+
+	ref := time.Date(2018, 3, 1, 23, 59, 59, 0, time.UTC)
+	assert.Equal(ref, fromMidnight(sinceMidnight(ref), ref))
+
+	// remote clock is already past midnight
+	assert.Equal(time.Date(2018, 3, 2, 0, 0, 1, 0, time.UTC), fromMidnight(1000, ref))
+
+	// remote clock is still before midnight
+	ref = time.Date(2018, 3, 2, 0, 0, 1, 0, time.UTC)
+	assert.Equal(time.Date(2018, 3, 1, 23, 59, 59, 0, time.UTC), fromMidnight(86399000, ref))
+}
+
+func TestProbe(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	if b, err := os.ReadFile("/proc/sys/net/ipv4/icmp_echo_enable_probe"); err != nil || string(b) != "1\n" {
+		t.Skip("PROBE responder not enabled (net.ipv4.icmp_echo_enable_probe)")
+	}
+
+	pinger, err := New("0.0.0.0", "")
+	require.NoError(err)
+	defer pinger.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	lo := &net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}
+
+	status, err := pinger.Probe(ctx, lo, InterfaceIdentifier{Name: "lo"})
+	require.NoError(err)
+	assert.True(status.Active)
+	assert.True(status.IPv4)
+	assert.NotZero(status.RTT)
+
+	status, err = pinger.Probe(ctx, lo, InterfaceIdentifier{Addr: net.IPv4(127, 0, 0, 1)})
+	require.NoError(err)
+	assert.True(status.Active)
+
+	_, err = pinger.Probe(ctx, lo, InterfaceIdentifier{Name: "nonexistent0"})
+	assert.Equal(&ProbeError{Code: 2}, err)
+
+	_, err = pinger.Probe(ctx, lo, InterfaceIdentifier{Name: "lo", Index: 1})
+	assert.Error(err)
+}
+
+func TestTCPProber(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	dst := &net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}
+
+	var prober Prober = &TCPProber{Port: port}
+	rtt, err := prober.PingContext(ctx, dst)
+	assert.NoError(err)
+	assert.NotZero(rtt)
+
+	// refused connections count as answer
+	ln.Close()
+	rtt, err = prober.PingContext(ctx, dst)
+	assert.NoError(err)
+	assert.NotZero(rtt)
+}
+
+func TestUDPProber(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(err)
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	dst := &net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}
+
+	// echo server
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if string(buf[:n]) == "ping" {
+				conn.WriteTo(buf[:n], addr)
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var prober Prober = &UDPProber{Port: port, Payload: []byte("ping")}
+	rtt, err := prober.PingContext(ctx, dst)
+	assert.NoError(err)
+	assert.NotZero(rtt)
+
+	// no reply
+	shortCtx, shortCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer shortCancel()
+	_, err = (&UDPProber{Port: port, Payload: []byte("silence")}).PingContext(shortCtx, dst)
+	assert.True(isTimeout(err), "%v", err)
+
+	// ICMP port unreachable counts as answer
+	conn.Close()
+	rtt, err = prober.PingContext(ctx, dst)
+	assert.NoError(err)
+	assert.NotZero(rtt)
+}
+
+func TestReceiveExtensions(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	pinger := Pinger{requests: make(map[requestKey]request)}
+	req := simpleRequest{}
+	req.setDestination(net.IPv4(198, 51, 100, 1))
+	req.init()
+	pinger.requests[requestKey{classEcho, 0x12340001}] = &req
+
+	// the echo request as sent by us
+	echo, err := (&icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{ID: 0x1234, Seq: 1, Data: make([]byte, 56)},
+	}).Marshal(nil)
+	require.NoError(err)
+	hdr, err := (&ipv4.Header{
+		Version:  4,
+		Len:      ipv4.HeaderLen,
+		TotalLen: ipv4.HeaderLen + len(echo),
+		TTL:      1,
+		Protocol: ProtocolICMP,
+		Src:      net.IPv4(192, 0, 2, 1),
+		Dst:      net.IPv4(198, 51, 100, 1),
+	}).Marshal()
+	require.NoError(err)
+
+	// the router's answer
+	msg, err := (&icmp.Message{
+		Type: ipv4.ICMPTypeTimeExceeded,
+		Body: &icmp.TimeExceeded{
+			Data: append(hdr, echo...),
+			Extensions: []icmp.Extension{
+				&icmp.MPLSLabelStack{Class: 1, Type: 1, Labels: []icmp.MPLSLabel{
+					{Label: 16014, TC: 0, S: true, TTL: 1},
+				}},
+				&icmp.InterfaceInfo{Class: 2, Type: 0x0a, Interface: &net.Interface{Index: 15, Name: "ge-0/0/1"}},
+			},
+		},
+	}).Marshal(nil)
+	require.NoError(err)
+
+	router := net.IPv4(203, 0, 113, 1)
+	pinger.receive(ProtocolICMP, msg, router, time.Now())
+
+	select {
+	case <-req.wait:
+	default:
+		t.Fatal("request not finished")
+	}
+	assert.Empty(pinger.requests)
+
+	var exceeded *TimeExceededError
+	require.ErrorAs(req.result, &exceeded)
+	assert.Equal("time exceeded", exceeded.Error())
+	assert.True(router.Equal(exceeded.Source))
+	assert.Equal([]icmp.MPLSLabel{{Label: 16014, S: true, TTL: 1}}, exceeded.Extensions.MPLSLabels())
+
+	ifi := exceeded.Extensions.Interface(InterfaceRoleIncoming)
+	require.NotNil(ifi)
+	assert.Equal("ge-0/0/1", ifi.Interface.Name)
+	assert.Equal(15, ifi.Interface.Index)
+	assert.Nil(exceeded.Extensions.Interface(InterfaceRoleOutgoing))
+}
+
+func TestVerifySource(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dst := net.IPv4(198, 51, 100, 1)
+	other := net.IPv4(198, 51, 100, 2)
+	key := requestKey{classEcho, 0x12340001}
+
+	// newRequest enqueues a request to dst
+	newRequest := func(pinger *Pinger) *simpleRequest {
+		req := simpleRequest{}
+		req.setDestination(dst)
+		req.init()
+		pinger.requests[key] = &req
+		return &req
+	}
+	// receive passes msg from source to the Pinger and returns whether
+	// req is finished
+	receive := func(pinger *Pinger, req *simpleRequest, msg icmp.Message, source net.IP) bool {
+		b, err := msg.Marshal(nil)
+		require.NoError(err)
+		pinger.receive(ProtocolICMP, b, source, time.Now())
+
+		select {
+		case <-req.wait:
+			return true
+		default:
+			return false
+		}
+	}
+	reply := icmp.Message{
+		Type: ipv4.ICMPTypeEchoReply,
+		Body: &icmp.Echo{ID: 0x1234, Seq: 1},
+	}
+
+	pinger := Pinger{requests: make(map[requestKey]request)}
+	req := newRequest(&pinger)
+	assert.True(receive(&pinger, req, reply, dst))
+	assert.NoError(req.result)
+
+	// a spoofed reply does not finish the request
+	req = newRequest(&pinger)
+	assert.False(receive(&pinger, req, reply, other))
+	assert.Contains(pinger.requests, key)
+	assert.True(receive(&pinger, req, reply, dst))
+	assert.NoError(req.result)
+
+	var mismatch *SourceMismatchError
+	require.ErrorAs(pinger.verify(req, other, nil, nil), &mismatch)
+	assert.Equal("reply from 198.51.100.2 instead of 198.51.100.1", mismatch.Error())
+	assert.Nil(mismatch.Err)
+
+	pinger.AcceptAnySource = true
+	req = newRequest(&pinger)
+	assert.True(receive(&pinger, req, reply, other))
+	assert.NoError(req.result)
+
+	// ICMP error quoting a request to another destination
+	echo, err := (&icmp.Message{Type: ipv4.ICMPTypeEcho, Body: reply.Body}).Marshal(nil)
+	require.NoError(err)
+	hdr, err := (&ipv4.Header{
+		Version:  4,
+		Len:      ipv4.HeaderLen,
+		TotalLen: ipv4.HeaderLen + len(echo),
+		Protocol: ProtocolICMP,
+		Src:      net.IPv4(192, 0, 2, 1),
+		Dst:      other,
+	}).Marshal()
+	require.NoError(err)
+
+	unreach := icmp.Message{
+		Type: ipv4.ICMPTypeDestinationUnreachable,
+		Code: 1,
+		Body: &icmp.DstUnreach{Data: append(hdr, echo...)},
+	}
+	req = newRequest(&pinger)
+	assert.False(receive(&pinger, req, unreach, net.IPv4(203, 0, 113, 1)))
+	assert.Contains(pinger.requests, key)
+
+	unreachable := &DestinationUnreachableError{}
+	require.ErrorAs(pinger.verify(req, nil, other, unreachable), &mismatch)
+	assert.True(other.Equal(mismatch.Source))
+	assert.Same(unreachable, mismatch.Err)
+	assert.NotErrorAs(mismatch, new(*DestinationUnreachableError))
+}
+
+func TestReceiveOtherType(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dst := net.IPv4(198, 51, 100, 1)
+	pinger := Pinger{requests: make(map[requestKey]request)}
+	req := probeRequest{}
+	req.setDestination(dst)
+	req.init()
+	key := requestKey{classExtendedEcho, 0x12340001}
+	pinger.requests[key] = &req
+
+	// an echo reply with the same id and sequence number is ignored
+	b, err := (&icmp.Message{
+		Type: ipv4.ICMPTypeEchoReply,
+		Body: &icmp.Echo{ID: 0x1234, Seq: 1},
+	}).Marshal(nil)
+	require.NoError(err)
+	pinger.receive(ProtocolICMP, b, dst, time.Now())
+	assert.Contains(pinger.requests, key)
+
+	b, err = (&icmp.Message{
+		Type: ipv4.ICMPTypeExtendedEchoReply,
+		Body: &icmp.ExtendedEchoReply{ID: 0x1234, Seq: 1, State: 1, Active: true, IPv4: true},
+	}).Marshal(nil)
+	require.NoError(err)
+	pinger.receive(ProtocolICMP, b, dst, time.Now())
+
+	select {
+	case <-req.wait:
+	default:
+		t.Fatal("request not finished")
+	}
+	assert.NoError(req.result)
+	assert.Empty(pinger.requests)
+	if assert.NotNil(req.reply) {
+		assert.True(req.reply.Active)
+	}
+}
+
+func TestPingOptions(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	pinger, err := New("0.0.0.0", "")
+	require.NoError(err)
+	defer pinger.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	lo := &net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}
+
+	reply, err := pinger.PingOptions(ctx, lo, IPOptions{RecordRoute: true})
+	require.NoError(err)
+	assert.NotZero(reply.RTT)
+	require.NotEmpty(reply.Route)
+	for _, hop := range reply.Route {
+		assert.True(hop.IsLoopback(), hop)
+	}
+
+	reply, err = pinger.PingOptions(ctx, lo, IPOptions{Timestamp: TimestampAndAddress})
+	require.NoError(err)
+	require.NotEmpty(reply.Timestamps)
+	for _, ts := range reply.Timestamps {
+		assert.True(ts.Addr.IsLoopback(), ts.Addr)
+		assert.True(ts.Standard)
+		assert.WithinDuration(time.Now(), ts.Time, time.Second)
+	}
+
+	// regular pings are unaffected
+	_, err = pinger.PingContext(ctx, lo)
+	assert.NoError(err)
+
+	// rebinding replaces the raw socket
+	raw := pinger.raw4
+	require.NoError(pinger.Rebind())
+	_, err = pinger.PingOptions(ctx, lo, IPOptions{RecordRoute: true})
+	assert.NoError(err)
+	assert.NotSame(raw, pinger.raw4)
+
+	_, err = pinger.PingOptions(ctx, lo, IPOptions{RecordRoute: true, Timestamp: TimestampOnly})
+	assert.Error(err)
+}
+
+func TestPingWith(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	pinger, err := New("0.0.0.0", "")
+	require.NoError(err)
+	defer pinger.Close()
+
+	// capture the echo requests on the loopback interface
+	c, err := net.ListenPacket("ip4:icmp", "127.0.0.1")
+	require.NoError(err)
+	defer c.Close()
+	sniffer, err := ipv4.NewRawConn(c)
+	require.NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	lo := &net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}
+
+	var payload Payload
+	payload.Resize(100)
+	_, err = pinger.PingWith(ctx, lo, EchoOptions{Payload: payload, TTL: 3, TOS: 0xb8})
+	require.NoError(err)
+
+	sniffer.SetReadDeadline(time.Now().Add(time.Second))
+	rb := make([]byte, 1500)
+	for {
+		hdr, body, _, err := sniffer.ReadFrom(rb)
+		require.NoError(err)
+		m, err := icmp.ParseMessage(ProtocolICMP, body)
+		if err != nil || m.Type != ipv4.ICMPTypeEcho {
+			continue
+		}
+		assert.Equal(3, hdr.TTL)
+		assert.Equal(0xb8, hdr.TOS)
+		assert.Equal([]byte(payload), m.Body.(*icmp.Echo).Data)
+		break
+	}
+
+	// the socket options are restored
+	ttl, err := pinger.conn4.(*icmp.PacketConn).IPv4PacketConn().TTL()
+	require.NoError(err)
+	assert.NotEqual(3, ttl)
+}
+
+type staticResolver []net.IPAddr
+
+func (r staticResolver) LookupIPAddr(context.Context, string) ([]net.IPAddr, error) {
+	return r, nil
+}
+
+func TestPingHost(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	pinger, err := New("0.0.0.0", "::")
+	require.NoError(err)
+	defer pinger.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	lo4 := net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}
+	lo6 := net.IPAddr{IP: net.IPv6loopback}
+	resolver := staticResolver{lo4, {IP: net.IPv4(127, 0, 0, 2)}, lo6}
+
+	replies, err := pinger.PingHost(ctx, "localhost", HostOptions{Resolver: resolver})
+	require.NoError(err)
+	require.Len(replies, 1)
+	assert.Equal(lo6, replies[0].Addr)
+
+	replies, err = pinger.PingHost(ctx, "localhost", HostOptions{Resolver: resolver, Strategy: RaceAddresses, PreferIPv4: true})
+	require.NoError(err)
+	require.Len(replies, 1)
+	assert.Equal(lo4, replies[0].Addr)
+	assert.NotZero(replies[0].RTT)
+
+	replies, err = pinger.PingHost(ctx, "localhost", HostOptions{Resolver: resolver, Strategy: AllAddresses})
+	require.NoError(err)
+	require.Len(replies, 3)
+	for _, reply := range replies {
+		assert.NoError(reply.Err, reply.Addr.String())
+	}
+
+	// real resolver
+	replies, err = pinger.PingHost(ctx, "127.0.0.1", HostOptions{})
+	require.NoError(err)
+	assert.Equal("127.0.0.1", replies[0].Addr.String())
+}
+
+func TestSortAddrs(t *testing.T) {
+	assert := assert.New(t)
+
+	a4 := net.IPAddr{IP: net.IPv4(192, 0, 2, 1)}
+	b4 := net.IPAddr{IP: net.IPv4(192, 0, 2, 2)}
+	a6 := net.IPAddr{IP: net.ParseIP("2001:db8::1")}
+
+	pinger := Pinger{conn4: &net.IPConn{}, conn6: &net.IPConn{}}
+	assert.Equal([]net.IPAddr{a6, a4, b4}, pinger.sortAddrs([]net.IPAddr{a4, b4, a6}, false))
+	assert.Equal([]net.IPAddr{a4, a6, b4}, pinger.sortAddrs([]net.IPAddr{a4, b4, a6}, true))
+
+	pinger.conn6 = nil
+	assert.Equal([]net.IPAddr{a4, b4}, pinger.sortAddrs([]net.IPAddr{a4, b4, a6}, false))
+}
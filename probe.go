@@ -0,0 +1,148 @@
+package ping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Interface Identification Object sub-types (RFC 8335, section 2.1)
+const (
+	interfaceByName    = 1
+	interfaceByIndex   = 2
+	interfaceByAddress = 3
+
+	classInterfaceIdent = 3
+)
+
+// address family numbers (see golang.org/x/net/internal/iana)
+const (
+	afiIPv4 = 1
+	afiIPv6 = 2
+)
+
+// InterfaceIdentifier identifies the interface queried by Probe. Exactly
+// one of Name, Index and Addr must be set.
+type InterfaceIdentifier struct {
+	Name  string // name of an interface on the proxy node
+	Index int    // index of an interface on the proxy node
+	Addr  net.IP // address of an interface
+
+	// Neighbor indicates that Addr belongs to a neighbor of the proxy
+	// node, instead of the proxy node itself.
+	Neighbor bool
+}
+
+// extension converts the identifier into an ICMP extension object and
+// the value of the L-bit.
+func (ii *InterfaceIdentifier) extension() (*icmp.InterfaceIdent, bool, error) {
+	ident := &icmp.InterfaceIdent{Class: classInterfaceIdent}
+	local := true
+
+	switch {
+	case ii.Name != "" && ii.Index == 0 && ii.Addr == nil:
+		ident.Type = interfaceByName
+		ident.Name = ii.Name
+	case ii.Name == "" && ii.Index > 0 && ii.Addr == nil:
+		ident.Type = interfaceByIndex
+		ident.Index = ii.Index
+	case ii.Name == "" && ii.Index == 0 && ii.Addr != nil:
+		ident.Type = interfaceByAddress
+		if ip4 := ii.Addr.To4(); ip4 != nil {
+			ident.AFI, ident.Addr = afiIPv4, ip4
+		} else {
+			ident.AFI, ident.Addr = afiIPv6, ii.Addr.To16()
+		}
+		local = !ii.Neighbor
+	default:
+		return nil, false, errors.New("need exactly one of interface name, index or address")
+	}
+	return ident, local, nil
+}
+
+// InterfaceStatus is the status of an interface, as reported in an ICMP
+// Extended Echo Reply.
+type InterfaceStatus struct {
+	RTT    time.Duration // round trip time
+	State  int           // neighbor reachability state, for neighbors only (see RFC 8335, section 3)
+	Active bool          // interface is active
+	IPv4   bool          // interface runs IPv4
+	IPv6   bool          // interface runs IPv6
+}
+
+// ProbeError is returned when the proxy node could not answer a query.
+type ProbeError struct {
+	Code int // ICMP code of the Extended Echo Reply
+}
+
+func (e *ProbeError) Error() string {
+	switch e.Code {
+	case 1:
+		return "malformed query"
+	case 2:
+		return "no such interface"
+	case 3:
+		return "no such table entry"
+	case 4:
+		return "multiple interfaces satisfy query"
+	}
+	return fmt.Sprintf("extended echo reply code %d", e.Code)
+}
+
+// A probeRequest is a simpleRequest keeping the reply's body.
+type probeRequest struct {
+	simpleRequest
+	reply *icmp.ExtendedEchoReply
+}
+
+func (req *probeRequest) handleBody(body icmp.MessageBody) {
+	req.reply, _ = body.(*icmp.ExtendedEchoReply)
+}
+
+// Probe sends an ICMP Extended Echo Request (RFC 8335) to the proxy node,
+// querying the status of the identified interface, and waits for an
+// answer until the context is done.
+//
+// Note that the sequence number of extended echo messages has only 8 bits,
+// so they are counted separately from the other requests.
+func (pinger *Pinger) Probe(ctx context.Context, proxyNode *net.IPAddr, ii InterfaceIdentifier) (*InterfaceStatus, error) {
+	ident, local, err := ii.extension()
+	if err != nil {
+		return nil, err
+	}
+
+	req := probeRequest{}
+	key, err := pinger.send(proxyNode, &req, ipv4.ICMPTypeExtendedEchoRequest, ipv6.ICMPTypeExtendedEchoRequest, func(id, _ uint16) icmp.MessageBody {
+		return &icmp.ExtendedEchoRequest{
+			ID:         int(id),
+			Seq:        int(uint8(atomic.AddUint32(&pinger.probeSequence, 1))),
+			Local:      local,
+			Extensions: []icmp.Extension{ident},
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = pinger.await(ctx, key, &req.simpleRequest); err != nil {
+		return nil, err
+	}
+	if req.reply == nil || req.tFinish == nil {
+		return nil, errors.New("invalid extended echo reply")
+	}
+
+	return &InterfaceStatus{
+		RTT:    req.tFinish.Sub(req.tStart),
+		State:  req.reply.State,
+		Active: req.reply.Active,
+		IPv4:   req.reply.IPv4,
+		IPv6:   req.reply.IPv6,
+	}, nil
+}
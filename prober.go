@@ -0,0 +1,110 @@
+package ping
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// A Prober measures the round trip time to a destination. It returns
+// a timeout error (a net.Error) if no answer is received before the
+// context is done.
+//
+// *Pinger is a Prober sending ICMP Echo Requests. TCPProber and
+// UDPProber are useful for hosts which filter ICMP.
+type Prober interface {
+	PingContext(ctx context.Context, destination *net.IPAddr) (time.Duration, error)
+}
+
+var (
+	_ Prober = (*Pinger)(nil)
+	_ Prober = (*TCPProber)(nil)
+	_ Prober = (*UDPProber)(nil)
+)
+
+// TCPProber measures the time needed to establish a TCP connection.
+// A refused connection (i.e. a RST instead of a SYN-ACK) counts as
+// answer as well, since the destination is obviously reachable.
+type TCPProber struct {
+	Port int
+}
+
+// PingContext connects to the destination and closes the connection
+// immediately.
+func (p *TCPProber) PingContext(ctx context.Context, destination *net.IPAddr) (time.Duration, error) {
+	var d net.Dialer
+
+	tStart := time.Now()
+	conn, err := d.DialContext(ctx, "tcp", hostPort(destination, p.Port))
+	rtt := time.Since(tStart)
+
+	if err == nil {
+		conn.Close()
+		return rtt, nil
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return rtt, nil
+	}
+	if ctx.Err() != nil {
+		return 0, &timeoutError{}
+	}
+	return 0, err
+}
+
+// UDPProber sends a datagram and waits for either a reply or an ICMP
+// Port Unreachable message. Be aware that an open port silently
+// discarding the payload is indistinguishable from a lost packet.
+type UDPProber struct {
+	Port    int
+	Payload []byte // datagram content, may be empty
+}
+
+// PingContext sends the payload to the destination and waits for an
+// answer.
+func (p *UDPProber) PingContext(ctx context.Context, destination *net.IPAddr) (time.Duration, error) {
+	var d net.Dialer
+
+	conn, err := d.DialContext(ctx, "udp", hostPort(destination, p.Port))
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// unblock Read on cancellation
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	tStart := time.Now()
+	if _, err = conn.Write(p.Payload); err != nil {
+		return 0, err
+	}
+
+	_, err = conn.Read(make([]byte, 1500))
+	rtt := time.Since(tStart)
+
+	if err == nil || errors.Is(err, syscall.ECONNREFUSED) {
+		return rtt, nil
+	}
+	if ctx.Err() != nil || errors.Is(err, os.ErrDeadlineExceeded) {
+		return 0, &timeoutError{}
+	}
+	return 0, err
+}
+
+func hostPort(destination *net.IPAddr, port int) string {
+	host := destination.IP.String()
+	if destination.Zone != "" {
+		host += "%" + destination.Zone
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
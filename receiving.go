@@ -1,7 +1,7 @@
 package ping
 
 import (
-	"fmt"
+	"errors"
 	"net"
 	"time"
 
@@ -17,21 +17,21 @@ func (pinger *Pinger) receiver(proto int, conn net.PacketConn) {
 
 	// read incoming packets
 	for {
-		if n, source, err := conn.ReadFrom(rb); err != nil {
-			if netErr, ok := err.(net.Error); !ok || !netErr.Temporary() { //nolint:staticcheck
-				break // socket gone
-			}
-		} else {
+		n, source, err := conn.ReadFrom(rb)
+		if err == nil {
 			pinger.receive(proto, rb[:n], source.(*net.IPAddr).IP, time.Now())
+			continue
+		}
+		if netErr, ok := err.(net.Error); ok && netErr.Temporary() { //nolint:staticcheck
+			continue
 		}
-	}
 
-	// close running requests
-	pinger.mtx.RLock()
-	for _, req := range pinger.requests {
-		req.handleReply(errClosed, nil, nil)
+		// socket gone
+		if !errors.Is(err, net.ErrClosed) {
+			pinger.fail(err)
+		}
+		break
 	}
-	pinger.mtx.RUnlock()
 
 	// Close() waits for us
 	pinger.wg.Done()
@@ -41,75 +41,197 @@ func (pinger *Pinger) receiver(proto int, conn net.PacketConn) {
 // If that succeeds, the body will given to process() for further processing.
 func (pinger *Pinger) receive(proto int, bytes []byte, addr net.IP, t time.Time) {
 	// parse message
-	m, err := icmp.ParseMessage(proto, bytes)
+	m, err := parseMessage(proto, bytes)
 	if err != nil {
 		return
 	}
 
 	// evaluate message
 	switch m.Type {
-	case ipv4.ICMPTypeEchoReply, ipv6.ICMPTypeEchoReply:
-		pinger.process(m.Body, nil, addr, &t)
+	case ipv4.ICMPTypeEchoReply, ipv6.ICMPTypeEchoReply, ipv4.ICMPTypeTimestampReply:
+		pinger.process(m.Body, nil, addr, nil, &t)
+
+	case ipv4.ICMPTypeExtendedEchoReply, ipv6.ICMPTypeExtendedEchoReply:
+		var result error
+		if m.Code != 0 {
+			result = &ProbeError{Code: m.Code}
+		}
+		pinger.process(m.Body, result, addr, nil, &t)
 
 	case ipv4.ICMPTypeDestinationUnreachable, ipv6.ICMPTypeDestinationUnreachable:
-		body := m.Body.(*icmp.DstUnreach)
-		if body == nil {
+		body, ok := m.Body.(*icmp.DstUnreach)
+		if !ok || body == nil {
 			return
 		}
+		pinger.processError(proto, body.Data, &DestinationUnreachableError{
+			Type:       m.Type,
+			Code:       m.Code,
+			Source:     addr,
+			Extensions: body.Extensions,
+		})
 
-		var bodyData []byte
-		switch proto {
-		case ProtocolICMP:
-			// parse header of original IPv4 packet
-			hdr, err := ipv4.ParseHeader(body.Data)
-			if err != nil {
-				return
-			}
-			bodyData = body.Data[hdr.Len:]
-		case ProtocolICMPv6:
-			// parse header of original IPv6 packet (we don't need the actual
-			// header, but want to detect parsing errors)
-			_, err := ipv6.ParseHeader(body.Data)
-			if err != nil {
-				return
-			}
-			bodyData = body.Data[ipv6.HeaderLen:]
-		default:
+	case ipv4.ICMPTypeTimeExceeded, ipv6.ICMPTypeTimeExceeded:
+		body, ok := m.Body.(*icmp.TimeExceeded)
+		if !ok || body == nil {
 			return
 		}
+		pinger.processError(proto, body.Data, &TimeExceededError{
+			Type:       m.Type,
+			Code:       m.Code,
+			Source:     addr,
+			Extensions: body.Extensions,
+		})
 
-		// parse ICMP message after the IP header
-		msg, err := icmp.ParseMessage(proto, bodyData)
+	default:
+		if pinger.LogUnexpectedPackets {
+			pinger.logger().Info("unexpected message", "source", addr, "type", m.Type, "code", m.Code)
+		}
+	}
+}
+
+// processError evaluates the original datagram quoted in an ICMP error
+// message and fails the corresponding request with the given error.
+func (pinger *Pinger) processError(proto int, data []byte, result error) {
+	var bodyData []byte
+	var quoted net.IP
+	switch proto {
+	case ProtocolICMP:
+		// parse header of original IPv4 packet
+		hdr, err := ipv4.ParseHeader(data)
+		if err != nil {
+			return
+		}
+		bodyData = data[hdr.Len:]
+		quoted = hdr.Dst
+	case ProtocolICMPv6:
+		// parse header of original IPv6 packet
+		hdr, err := ipv6.ParseHeader(data)
 		if err != nil {
 			return
 		}
-		pinger.process(msg.Body, fmt.Errorf("%s", m.Type), nil, nil)
+		bodyData = data[ipv6.HeaderLen:]
+		quoted = hdr.Dst
+	default:
+		return
 	}
+
+	// parse ICMP message after the IP header
+	msg, err := parseMessage(proto, bodyData)
+	if err != nil {
+		return
+	}
+	pinger.process(msg.Body, result, nil, quoted, nil)
 }
 
-// process will finish a currently running Echo Request, if the body is
-// an ICMP Echo reply to a request from us.
-func (pinger *Pinger) process(body icmp.MessageBody, result error, addr net.IP, tRecv *time.Time) {
-	echo, ok := body.(*icmp.Echo)
-	if !ok || echo == nil {
+// process will finish a currently running request, if the body is
+// an ICMP Echo, Timestamp or Extended Echo reply to a request from us.
+// For ICMP error messages, quoted is the destination of the quoted
+// request and addr is nil.
+func (pinger *Pinger) process(body icmp.MessageBody, result error, addr, quoted net.IP, tRecv *time.Time) {
+	key, ok := keyOf(body)
+	if !ok {
 		if pinger.LogUnexpectedPackets {
-			log.Infof("expected *icmp.Echo, got %#v", body)
+			pinger.logger().Info("unexpected message body", "source", addr, "body", body)
 		}
 		return
 	}
 
-	idseq := (uint32(uint16(echo.ID)) << 16) | uint32(uint16(echo.Seq))
-
 	// search for existing running echo request
 	pinger.mtx.Lock()
-	req := pinger.requests[idseq]
-	if _, ok := req.(*simpleRequest); ok {
-		// a simpleRequest is finished on the first reply
-		delete(pinger.requests, idseq)
+	req := pinger.requests[key]
+	if _, ok := req.(*optionsRequest); ok && result == nil {
+		// replies are evaluated by rawReceiver, which sees the IP header
+		pinger.mtx.Unlock()
+		return
+	}
+	if req == nil {
+		pinger.mtx.Unlock()
+		if pinger.LogUnexpectedPackets {
+			pinger.logger().Info("no matching request", "source", addr, "id", key.idseq>>16, "seq", key.idseq&0xffff, "error", result)
+		}
+		return
+	}
+	if err := pinger.verify(req, addr, quoted, result); err != nil {
+		// possibly spoofed, wait for the genuine reply
+		pinger.mtx.Unlock()
+		pinger.logger().Debug("ignoring reply", "id", key.idseq>>16, "seq", key.idseq&0xffff, "error", err)
+		return
+	}
+	if _, ok := req.(*multiRequest); !ok {
+		// all but multiRequests are finished on the first reply
+		delete(pinger.requests, key)
 	}
 	pinger.mtx.Unlock()
 
-	if req != nil {
-		req.handleReply(result, addr, tRecv)
+	if result != nil {
+		pinger.logger().Debug("request failed", "destination", req.destination(), "source", addr, "id", key.idseq>>16, "seq", key.idseq&0xffff, "error", result)
+	}
+
+	if bh, ok := req.(bodyHandler); ok && result == nil {
+		bh.handleBody(body)
+	}
+	req.handleReply(result, addr, tRecv)
+}
+
+// verify checks the source of a reply, or the destination of a request
+// quoted in an ICMP error message, against the destination of the request.
+func (pinger *Pinger) verify(req request, addr, quoted net.IP, result error) error {
+	dst := req.destination()
+	switch {
+	case dst == nil:
+		return nil
+	case quoted != nil && !quoted.Equal(dst):
+		return &SourceMismatchError{Destination: dst, Source: quoted, Err: result}
+	case addr != nil && !pinger.AcceptAnySource && !addr.Equal(dst):
+		return &SourceMismatchError{Destination: dst, Source: addr}
+	}
+	return nil
+}
+
+// messageClass distinguishes requests of different message types with the
+// same id and sequence number.
+type messageClass uint8
+
+const (
+	classEcho messageClass = iota
+	classTimestamp
+	classExtendedEcho
+)
+
+// requestKey identifies a running request by its message class and the
+// combined id+sequence number.
+type requestKey struct {
+	class messageClass
+	idseq uint32
+}
+
+// keyOf extracts the request key from a request or reply message body.
+func keyOf(body icmp.MessageBody) (requestKey, bool) {
+	var class messageClass
+	var id, seq int
+	switch b := body.(type) {
+	case *icmp.Echo:
+		if b == nil {
+			return requestKey{}, false
+		}
+		class, id, seq = classEcho, b.ID, b.Seq
+	case *timestamp:
+		if b == nil {
+			return requestKey{}, false
+		}
+		class, id, seq = classTimestamp, b.ID, b.Seq
+	case *icmp.ExtendedEchoRequest:
+		if b == nil {
+			return requestKey{}, false
+		}
+		class, id, seq = classExtendedEcho, b.ID, b.Seq
+	case *icmp.ExtendedEchoReply:
+		if b == nil {
+			return requestKey{}, false
+		}
+		class, id, seq = classExtendedEcho, b.ID, b.Seq
+	default:
+		return requestKey{}, false
 	}
+	return requestKey{class, (uint32(uint16(id)) << 16) | uint32(uint16(seq))}, true
 }
@@ -4,12 +4,34 @@ import (
 	"net"
 	"sync"
 	"time"
+
+	"golang.org/x/net/icmp"
 )
 
 type request interface {
 	init()
 	close()
 	handleReply(error, net.IP, *time.Time)
+
+	// setDestination is called before the request is enqueued.
+	// destination returns nil if replies are accepted from any source.
+	setDestination(net.IP)
+	destination() net.IP
+}
+
+// requestDestination is embedded by requests verifying the source of
+// replies.
+type requestDestination struct {
+	dst net.IP
+}
+
+func (d *requestDestination) setDestination(ip net.IP) { d.dst = ip }
+func (d *requestDestination) destination() net.IP      { return d.dst }
+
+// A bodyHandler is a request interested in the body of a successful reply.
+// handleBody is called right before handleReply.
+type bodyHandler interface {
+	handleBody(icmp.MessageBody)
 }
 
 // A multiRequest is a currently running ICMP echo request waiting for multple answers.
@@ -28,6 +50,7 @@ type Reply struct {
 
 // A simpleRequest is a currently running ICMP echo request waiting for a single answer.
 type simpleRequest struct {
+	requestDestination
 	wait    chan struct{}
 	result  error
 	tStart  time.Time  // when was this packet sent?
@@ -76,6 +99,10 @@ func (req *multiRequest) init() {
 	req.tStart = time.Now()
 }
 
+// Replies to multicast requests come from any source.
+func (req *multiRequest) setDestination(net.IP) {}
+func (req *multiRequest) destination() net.IP   { return nil }
+
 func (req *multiRequest) close() {
 	req.mtx.Lock()
 	req.closed = true
@@ -101,3 +128,37 @@ func (req *multiRequest) handleReply(err error, addr net.IP, tRecv *time.Time) {
 		}
 	}()
 }
+
+// A hedgedRequest is one of several ICMP echo requests sent by
+// PingHedged, which share a common result channel.
+type hedgedRequest struct {
+	requestDestination
+	index   int // 1-based number of this probe
+	tStart  time.Time
+	replies chan<- hedgedReply // buffered, never blocks
+}
+
+type hedgedReply struct {
+	index int
+	rtt   time.Duration
+	err   error
+}
+
+func (req *hedgedRequest) init() {
+	req.tStart = time.Now()
+}
+
+func (req *hedgedRequest) close() {}
+
+// handleReply forwards the result to PingHedged.
+func (req *hedgedRequest) handleReply(err error, _ net.IP, tRecv *time.Time) {
+	reply := hedgedReply{index: req.index, err: err}
+	if err == nil && tRecv != nil {
+		reply.rtt = tRecv.Sub(req.tStart)
+	}
+
+	select {
+	case req.replies <- reply:
+	default:
+	}
+}
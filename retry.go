@@ -0,0 +1,125 @@
+package ping
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"time"
+)
+
+// Backoff returns the delay to wait before the given retry. The first
+// retry (i.e. the second attempt) has number 1.
+type Backoff func(retry int) time.Duration
+
+// FixedBackoff waits the same delay before each retry.
+func FixedBackoff(delay time.Duration) Backoff {
+	return func(int) time.Duration {
+		return delay
+	}
+}
+
+// ExponentialBackoff waits initial before the first retry and multiplies
+// the delay by factor for each subsequent retry, up to max (if max > 0).
+func ExponentialBackoff(initial time.Duration, factor float64, max time.Duration) Backoff {
+	return func(retry int) time.Duration {
+		delay := float64(initial) * math.Pow(factor, float64(retry-1))
+		if max > 0 && delay > float64(max) {
+			return max
+		}
+		return time.Duration(delay)
+	}
+}
+
+// Jitter randomizes the delays of b by up to ±fraction (e.g. 0.1 for
+// ±10%), to avoid synchronized retries of many concurrent pings.
+func Jitter(b Backoff, fraction float64) Backoff {
+	return func(retry int) time.Duration {
+		delay := float64(b(retry))
+		delay += delay * fraction * (2*rand.Float64() - 1)
+		if delay < 0 {
+			return 0
+		}
+		return time.Duration(delay)
+	}
+}
+
+// RetryPolicy configures PingRetry.
+type RetryPolicy struct {
+	Attempts int           // maximum number of echo requests (at least 1)
+	Timeout  time.Duration // timeout per attempt (0 = limited by the context only)
+	Backoff  Backoff       // delay between attempts (nil = back-to-back)
+
+	// RetryOnUnreachable also retries if a destination unreachable message
+	// was received. Timeouts are always retried, other errors never.
+	RetryOnUnreachable bool
+}
+
+// Attempt is the outcome of a single echo request sent by PingRetry.
+type Attempt struct {
+	Start time.Time     // when the attempt was started
+	RTT   time.Duration // round trip time, if successful
+	Err   error         // reason of failure, nil on success
+}
+
+// PingRetry sends ICMP echo requests according to the given policy until
+// a reply is received, the attempts are exhausted or the context is done.
+// It returns the outcomes of all attempts, in order, and the error of the
+// last attempt (or the context error, if the context ended first).
+func (pinger *Pinger) PingRetry(ctx context.Context, destination *net.IPAddr, policy RetryPolicy) ([]Attempt, error) {
+	if policy.Attempts < 1 {
+		return nil, errors.New("zero attempts")
+	}
+
+	attempts := make([]Attempt, 0, policy.Attempts)
+	for i := 0; i < policy.Attempts; i++ {
+		if i > 0 && policy.Backoff != nil {
+			timer := time.NewTimer(policy.Backoff(i))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return attempts, ctx.Err()
+			}
+		}
+
+		attempt := pinger.attempt(ctx, destination, policy.Timeout)
+		attempts = append(attempts, attempt)
+
+		if attempt.Err == nil {
+			return attempts, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return attempts, err
+		}
+		if !policy.retryable(attempt.Err) {
+			break
+		}
+	}
+
+	return attempts, attempts[len(attempts)-1].Err
+}
+
+func (pinger *Pinger) attempt(ctx context.Context, destination *net.IPAddr, timeout time.Duration) Attempt {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	a := Attempt{Start: time.Now()}
+	a.RTT, a.Err = pinger.PingContext(ctx, destination)
+	return a
+}
+
+// retryable decides whether err justifies another attempt.
+func (policy *RetryPolicy) retryable(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var unreach *DestinationUnreachableError
+	return policy.RetryOnUnreachable && errors.As(err, &unreach)
+}
@@ -15,6 +15,7 @@ import (
 
 // PingAttempts sends ICMP echo requests with a timeout per request, retrying upto `attempt` times .
 // Will finish early on success and return the round trip time of the last ping.
+// See PingRetry for configurable retry policies.
 func (pinger *Pinger) PingAttempts(destination *net.IPAddr, timeout time.Duration, attempts int) (rtt time.Duration, err error) {
 	if attempts < 1 {
 		err = errors.New("zero attempts")
@@ -42,28 +43,60 @@ func (pinger *Pinger) Ping(destination *net.IPAddr, timeout time.Duration) (time
 func (pinger *Pinger) PingContext(ctx context.Context, destination *net.IPAddr) (time.Duration, error) {
 	req := simpleRequest{}
 
-	idseq, err := pinger.sendRequest(destination, &req)
+	key, err := pinger.sendRequest(destination, &req)
 	if err != nil {
 		return 0, err
 	}
 
-	// wait for answer
-	select {
-	case <-req.wait:
-		// already dequeued
-		err = req.result
-	case <-ctx.Done():
-		// dequeue request
-		pinger.removeRequest(idseq)
-		err = &timeoutError{}
+	if err = pinger.await(ctx, key, &req); err != nil {
+		return 0, err
 	}
+	return req.roundTripTime()
+}
+
+// EchoOptions modify a single echo request sent by PingWith.
+type EchoOptions struct {
+	Payload Payload // payload to send instead of the Pinger's payload, if not nil
+	TTL     int     // TTL (or IPv6 hop limit), 0 keeps the socket's default
+	TOS     int     // IPv4 TOS (or IPv6 traffic class) byte, 0 keeps the socket's default
+}
 
+// An echoRequest is a simpleRequest sent with EchoOptions.
+type echoRequest struct {
+	simpleRequest
+	options EchoOptions
+}
+
+// PingWith is PingContext with options for the echo request. TTL and TOS
+// are socket options, which are changed just for this request.
+func (pinger *Pinger) PingWith(ctx context.Context, destination *net.IPAddr, opts EchoOptions) (time.Duration, error) {
+	req := echoRequest{options: opts}
+
+	key, err := pinger.sendRequest(destination, &req)
 	if err != nil {
 		return 0, err
 	}
+
+	if err = pinger.await(ctx, key, &req.simpleRequest); err != nil {
+		return 0, err
+	}
 	return req.roundTripTime()
 }
 
+// await waits for the answer to a simpleRequest, or until the context
+// is done.
+func (pinger *Pinger) await(ctx context.Context, key requestKey, req *simpleRequest) error {
+	select {
+	case <-req.wait:
+		// already dequeued
+		return req.result
+	case <-ctx.Done():
+		// dequeue request
+		pinger.removeRequest(key)
+		return &timeoutError{}
+	}
+}
+
 // PingMulticast sends a single echo request and returns a channel for the responses.
 // The channel will be closed on termination of the context.
 // An error is returned if the sending of the echo request fails.
@@ -77,7 +110,7 @@ func (pinger *Pinger) PingMulticast(destination *net.IPAddr, wait time.Duration)
 func (pinger *Pinger) PingMulticastContext(ctx context.Context, destination *net.IPAddr) (<-chan Reply, error) {
 	req := multiRequest{}
 
-	idseq, err := pinger.sendRequest(destination, &req)
+	key, err := pinger.sendRequest(destination, &req)
 	if err != nil {
 		return nil, err
 	}
@@ -86,7 +119,7 @@ func (pinger *Pinger) PingMulticastContext(ctx context.Context, destination *net
 		<-ctx.Done()
 
 		// dequeue request
-		pinger.removeRequest(idseq)
+		pinger.removeRequest(key)
 
 		req.close()
 	}()
@@ -94,49 +127,142 @@ func (pinger *Pinger) PingMulticastContext(ctx context.Context, destination *net
 	return req.replies, nil
 }
 
-// sendRequest marshals the payload and sends the packet.
-// It returns the combined id+sequence number and an error if the sending failed.
-func (pinger *Pinger) sendRequest(destination *net.IPAddr, req request) (uint32, error) {
-	id := uint16(pinger.Id)
-	seq := uint16(atomic.AddUint32(pinger.SequenceCounter, 1))
+// HedgedResult describes the outcome of PingHedged.
+type HedgedResult struct {
+	RTT   time.Duration // round trip time of the first reply
+	Sent  int           // number of echo requests sent
+	Probe int           // which echo request (starting at 1) was answered first, 0 if none
+}
 
-	idseq := (uint32(id) << 16) | uint32(seq)
+// PingHedged sends up to n echo requests, each spacing apart from the
+// previous one, and returns as soon as the first reply is received. The
+// remaining requests are canceled. The result gives a rough loss
+// indication: Probe > 1 means the earlier requests were lost (or answered
+// slower than the spacing).
+//
+// If no reply is received before the context is done, a timeout error
+// is returned.
+func (pinger *Pinger) PingHedged(ctx context.Context, destination *net.IPAddr, n int, spacing time.Duration) (HedgedResult, error) {
+	var result HedgedResult
+	if n < 1 {
+		return result, errors.New("zero attempts")
+	}
 
+	replies := make(chan hedgedReply, n)
+	keys := make([]requestKey, 0, n)
+	defer func() {
+		// dequeue outstanding requests
+		for _, key := range keys {
+			pinger.removeRequest(key)
+		}
+	}()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			req := hedgedRequest{index: len(keys) + 1, replies: replies}
+			key, err := pinger.sendRequest(destination, &req)
+			if err != nil {
+				return result, err
+			}
+			keys = append(keys, key)
+			result.Sent++
+
+			if result.Sent < n {
+				timer.Reset(spacing)
+			}
+		case reply := <-replies:
+			result.Probe = reply.index
+			result.RTT = reply.rtt
+			return result, reply.err
+		case <-ctx.Done():
+			return result, &timeoutError{}
+		}
+	}
+}
+
+// sendRequest marshals the payload and sends the packet.
+// It returns the key of the request and an error if the sending failed.
+func (pinger *Pinger) sendRequest(destination *net.IPAddr, req request) (requestKey, error) {
 	pinger.payloadMu.RLock()
 	defer pinger.payloadMu.RUnlock()
 
+	payload := pinger.payload
+	if ereq, ok := req.(*echoRequest); ok && ereq.options.Payload != nil {
+		payload = ereq.options.Payload
+	}
+
+	return pinger.send(destination, req, ipv4.ICMPTypeEcho, ipv6.ICMPTypeEchoRequest, func(id, seq uint16) icmp.MessageBody {
+		return &icmp.Echo{
+			ID:   int(id),
+			Seq:  int(seq),
+			Data: payload,
+		}
+	})
+}
+
+// send builds a message of type4 or type6 (depending on the destination's
+// address family) with a body returned by the given function and sends it.
+// A nil type denotes a message not available for that address family.
+// It returns the key of the request and an error if the sending failed.
+func (pinger *Pinger) send(destination *net.IPAddr, req request, type4, type6 icmp.Type, body func(id, seq uint16) icmp.MessageBody) (requestKey, error) {
+	id := uint16(pinger.Id)
+	seq := uint16(atomic.AddUint32(pinger.SequenceCounter, 1))
+
 	// build packet
 	wm := icmp.Message{
 		Code: 0,
-		Body: &icmp.Echo{
-			ID:   int(id),
-			Seq:  int(seq),
-			Data: pinger.payload,
-		},
+		Body: body(id, seq),
+	}
+
+	// the body may truncate the sequence number
+	key, ok := keyOf(wm.Body)
+	if !ok {
+		return key, errUnsupported
 	}
 
 	// Protocol specifics
 	var conn net.PacketConn
 	var lock *sync.Mutex
+	pinger.connMtx.RLock()
+	err := pinger.err
 	if destination.IP.To4() != nil {
-		wm.Type = ipv4.ICMPTypeEcho
+		wm.Type = type4
 		conn = pinger.conn4
 		lock = &pinger.write4
 	} else {
-		wm.Type = ipv6.ICMPTypeEchoRequest
+		wm.Type = type6
 		conn = pinger.conn6
 		lock = &pinger.write6
 	}
+	pinger.connMtx.RUnlock()
+	if err != nil {
+		return key, err
+	}
+	if wm.Type == nil {
+		return key, errUnsupported
+	}
+	if conn == nil {
+		return key, errNotBound
+	}
 
 	// serialize packet
 	wb, err := wm.Marshal(nil)
 	if err != nil {
-		return idseq, err
+		return key, err
 	}
 
 	// enqueue in currently running requests
+	req.setDestination(destination.IP)
 	pinger.mtx.Lock()
-	pinger.requests[idseq] = req
+	if _, exists := pinger.requests[key]; exists {
+		pinger.mtx.Unlock()
+		return key, errSequenceInUse
+	}
+	pinger.requests[key] = req
 	pinger.mtx.Unlock()
 
 	// start measurement (tStop is set in the receiving end)
@@ -144,16 +270,63 @@ func (pinger *Pinger) sendRequest(destination *net.IPAddr, req request) (uint32,
 	req.init()
 
 	// send request
-	_, err = conn.WriteTo(wb, destination)
+	if oreq, ok := req.(*optionsRequest); ok {
+		err = pinger.writeOptions(destination, wb, oreq.options)
+	} else if ereq, ok := req.(*echoRequest); ok {
+		err = writeEcho(conn, destination, wb, &ereq.options)
+	} else {
+		_, err = conn.WriteTo(wb, destination)
+	}
 	lock.Unlock()
 
 	// send failed, need to remove request from list
 	if err != nil {
+		pinger.logger().Debug("sending failed", "destination", destination, "id", id, "seq", seq, "type", wm.Type, "error", err)
 		req.close()
-		pinger.removeRequest(idseq)
+		pinger.removeRequest(key)
+
+		return key, err
+	}
+
+	return key, nil
+}
 
-		return idseq, err
+// writeEcho sends a message with the TTL and TOS of the options. They are
+// set on the socket for this single write, so the caller must hold the
+// write lock.
+func writeEcho(conn net.PacketConn, destination *net.IPAddr, wb []byte, opts *EchoOptions) error {
+	type sockopt struct {
+		get   func() (int, error)
+		set   func(int) error
+		value int
+	}
+
+	pc, ok := conn.(*icmp.PacketConn)
+	if !ok {
+		return errUnsupported
+	}
+
+	var sockopts []sockopt
+	if p4 := pc.IPv4PacketConn(); p4 != nil {
+		sockopts = []sockopt{{p4.TTL, p4.SetTTL, opts.TTL}, {p4.TOS, p4.SetTOS, opts.TOS}}
+	} else if p6 := pc.IPv6PacketConn(); p6 != nil {
+		sockopts = []sockopt{{p6.HopLimit, p6.SetHopLimit, opts.TTL}, {p6.TrafficClass, p6.SetTrafficClass, opts.TOS}}
+	}
+
+	for _, o := range sockopts {
+		if o.value == 0 {
+			continue
+		}
+		prev, err := o.get()
+		if err != nil {
+			return err
+		}
+		if err = o.set(o.value); err != nil {
+			return err
+		}
+		defer o.set(prev)
 	}
 
-	return idseq, nil
+	_, err := conn.WriteTo(wb, destination)
+	return err
 }
@@ -0,0 +1,176 @@
+package ping
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// nonStandardTimestamp is the high-order bit of an ICMP timestamp, which
+// indicates a value not measured in milliseconds since midnight UT.
+const nonStandardTimestamp = 1 << 31
+
+// timestamp is the body of ICMP Timestamp and Timestamp Reply messages
+// (RFC 792). It implements icmp.MessageBody.
+type timestamp struct {
+	ID        int    // identifier
+	Seq       int    // sequence number
+	Originate uint32 // time the sender last touched the message
+	Receive   uint32 // time the echoer first touched it on receipt
+	Transmit  uint32 // time the echoer last touched the message on sending it
+}
+
+// Len implements the Len method of icmp.MessageBody.
+func (t *timestamp) Len(_ int) int {
+	return 16
+}
+
+// Marshal implements the Marshal method of icmp.MessageBody.
+func (t *timestamp) Marshal(_ int) ([]byte, error) {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint16(b[0:2], uint16(t.ID))
+	binary.BigEndian.PutUint16(b[2:4], uint16(t.Seq))
+	binary.BigEndian.PutUint32(b[4:8], t.Originate)
+	binary.BigEndian.PutUint32(b[8:12], t.Receive)
+	binary.BigEndian.PutUint32(b[12:16], t.Transmit)
+	return b, nil
+}
+
+func parseTimestamp(b []byte) (*timestamp, error) {
+	if len(b) < 16 {
+		return nil, errors.New("timestamp message too short")
+	}
+	return &timestamp{
+		ID:        int(binary.BigEndian.Uint16(b[0:2])),
+		Seq:       int(binary.BigEndian.Uint16(b[2:4])),
+		Originate: binary.BigEndian.Uint32(b[4:8]),
+		Receive:   binary.BigEndian.Uint32(b[8:12]),
+		Transmit:  binary.BigEndian.Uint32(b[12:16]),
+	}, nil
+}
+
+// parseMessage wraps icmp.ParseMessage and additionally decodes the body
+// of timestamp messages, which golang.org/x/net/icmp leaves raw.
+func parseMessage(proto int, b []byte) (*icmp.Message, error) {
+	m, err := icmp.ParseMessage(proto, b)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Type == ipv4.ICMPTypeTimestamp || m.Type == ipv4.ICMPTypeTimestampReply {
+		if raw, ok := m.Body.(*icmp.RawBody); ok && raw != nil {
+			if m.Body, err = parseTimestamp(raw.Data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return m, nil
+}
+
+// sinceMidnight returns the milliseconds since midnight UT.
+func sinceMidnight(t time.Time) uint32 {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return uint32(t.Sub(midnight) / time.Millisecond)
+}
+
+// fromMidnight converts milliseconds since midnight UT into a point in
+// time closest to ref.
+func fromMidnight(ms uint32, ref time.Time) time.Time {
+	ref = ref.UTC()
+	midnight := time.Date(ref.Year(), ref.Month(), ref.Day(), 0, 0, 0, 0, time.UTC)
+	t := midnight.Add(time.Duration(ms) * time.Millisecond)
+
+	// the remote clock may already be on the next (or still on the
+	// previous) day
+	if d := t.Sub(ref); d > 12*time.Hour {
+		t = t.Add(-24 * time.Hour)
+	} else if d < -12*time.Hour {
+		t = t.Add(24 * time.Hour)
+	}
+	return t
+}
+
+// TimestampReply is the result of an ICMP Timestamp request.
+//
+// The remote timestamps have a resolution of one millisecond. They are
+// only valid, if Standard is true; otherwise the remote host reported
+// a value in an unspecified unit or reference (RFC 792).
+type TimestampReply struct {
+	RTT      time.Duration // round trip time, as measured locally
+	Standard bool          // whether Receive and Transmit are valid
+
+	Originate time.Time // when the request was sent (local clock)
+	Receive   time.Time // when the request was received (remote clock)
+	Transmit  time.Time // when the reply was sent (remote clock)
+	Returned  time.Time // when the reply was received (local clock)
+}
+
+// ForwardDelay estimates the one-way delay from us to the remote host.
+// It is only meaningful, if both clocks are in sync (see Offset).
+func (r *TimestampReply) ForwardDelay() time.Duration {
+	return r.Receive.Sub(r.Originate)
+}
+
+// ReturnDelay estimates the one-way delay from the remote host to us.
+// It is only meaningful, if both clocks are in sync (see Offset).
+func (r *TimestampReply) ReturnDelay() time.Duration {
+	return r.Returned.Sub(r.Transmit)
+}
+
+// Offset estimates the offset of the remote clock relative to the local
+// clock, assuming symmetric delays (as NTP does).
+func (r *TimestampReply) Offset() time.Duration {
+	return (r.Receive.Sub(r.Originate) + r.Transmit.Sub(r.Returned)) / 2
+}
+
+// A timestampRequest is a simpleRequest keeping the reply's body.
+type timestampRequest struct {
+	simpleRequest
+	reply *timestamp
+}
+
+func (req *timestampRequest) handleBody(body icmp.MessageBody) {
+	req.reply, _ = body.(*timestamp)
+}
+
+// PingTimestamp sends a single ICMP Timestamp request (IPv4 only) and
+// waits for an answer until the context is done.
+func (pinger *Pinger) PingTimestamp(ctx context.Context, destination *net.IPAddr) (*TimestampReply, error) {
+	req := timestampRequest{}
+
+	key, err := pinger.send(destination, &req, ipv4.ICMPTypeTimestamp, nil, func(id, seq uint16) icmp.MessageBody {
+		return &timestamp{
+			ID:        int(id),
+			Seq:       int(seq),
+			Originate: sinceMidnight(time.Now()),
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = pinger.await(ctx, key, &req.simpleRequest); err != nil {
+		return nil, err
+	}
+	if req.reply == nil || req.tFinish == nil {
+		return nil, errors.New("invalid timestamp reply")
+	}
+
+	reply := &TimestampReply{
+		RTT:       req.tFinish.Sub(req.tStart),
+		Standard:  (req.reply.Receive|req.reply.Transmit)&nonStandardTimestamp == 0,
+		Originate: req.tStart,
+		Returned:  *req.tFinish,
+	}
+	if reply.Standard {
+		reply.Receive = fromMidnight(req.reply.Receive, req.tStart)
+		reply.Transmit = fromMidnight(req.reply.Transmit, req.tStart)
+	}
+	return reply, nil
+}
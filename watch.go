@@ -0,0 +1,8 @@
+package ping
+
+// A RebindEvent is emitted by WatchAddresses after the sockets were
+// rebound.
+type RebindEvent struct {
+	Reason string // address or link changes which caused rebinding
+	Err    error  // nil if rebinding succeeded
+}
@@ -0,0 +1,247 @@
+package ping
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// watchSettle is the quiet period after address or link changes before
+// rebinding, so a burst of changes (e.g. a DHCP renewal) rebinds once.
+const watchSettle = 200 * time.Millisecond
+
+// rtnetlink multicast groups (see linux/rtnetlink.h)
+const (
+	rtmgrpLink       = 0x1
+	rtmgrpIPv4IfAddr = 0x10
+	rtmgrpIPv6IfAddr = 0x100
+)
+
+// WatchAddresses watches address and link changes via netlink and rebinds
+// the sockets (see Rebind) when a bind address is added to or removed from
+// an interface, or when the interface holding it (or named by the zone of
+// an IPv6 bind address) comes up. Removing a bind address fails the
+// Pinger until the address is added again.
+//
+// An event is sent to the returned channel after each rebinding, and
+// dropped if the channel is not drained. The channel is closed when the
+// context is done or the Pinger is closed.
+//
+// Unspecified bind addresses like 0.0.0.0 are not affected by address
+// changes. An error is returned if there is nothing to watch.
+func (pinger *Pinger) WatchAddresses(ctx context.Context) (<-chan RebindEvent, error) {
+	var w *addrWatch
+	var fd int
+	err := inNamespace(pinger.netns, func() (err error) {
+		if w, err = newAddrWatch(pinger.bind4, pinger.bind6); err != nil {
+			return err
+		}
+		fd, err = listenNetlink()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan RebindEvent, 16)
+	go pinger.watch(ctx, os.NewFile(uintptr(fd), "netlink"), w, events)
+	return events, nil
+}
+
+// listenNetlink opens a netlink socket receiving address and link
+// changes.
+func listenNetlink() (int, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC|syscall.SOCK_NONBLOCK, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return -1, os.NewSyscallError("socket", err)
+	}
+	sa := syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: rtmgrpLink | rtmgrpIPv4IfAddr | rtmgrpIPv6IfAddr,
+	}
+	if err = syscall.Bind(fd, &sa); err != nil {
+		syscall.Close(fd)
+		return -1, os.NewSyscallError("bind", err)
+	}
+	return fd, nil
+}
+
+// watch evaluates the netlink messages and rebinds the sockets after
+// relevant changes.
+func (pinger *Pinger) watch(ctx context.Context, f *os.File, w *addrWatch, events chan<- RebindEvent) {
+	defer close(events)
+
+	msgs := make(chan []syscall.NetlinkMessage)
+	go func() {
+		defer close(msgs)
+
+		b := make([]byte, 1<<16)
+		for {
+			n, err := f.Read(b)
+			if errors.Is(err, syscall.ENOBUFS) {
+				// messages were lost, better check again
+				msgs <- nil
+				continue
+			}
+			if err != nil {
+				if !errors.Is(err, os.ErrClosed) {
+					pinger.logger().Error("watching addresses failed", "error", err)
+				}
+				return
+			}
+			// the messages refer to the buffer
+			if m, err := syscall.ParseNetlinkMessage(append([]byte(nil), b[:n]...)); err == nil {
+				msgs <- m
+			}
+		}
+	}()
+
+	defer func() {
+		f.Close()
+		for range msgs {
+			// unblock the reader
+		}
+	}()
+
+	var reasons []string
+	var settle <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-pinger.done:
+			return
+		case m, ok := <-msgs:
+			if !ok {
+				return
+			}
+			if m == nil {
+				reasons = append(reasons, "netlink messages lost")
+			}
+			for i := range m {
+				if reason := w.handle(&m[i]); reason != "" {
+					reasons = append(reasons, reason)
+				}
+			}
+			if len(reasons) > 0 && settle == nil {
+				settle = time.After(watchSettle)
+			}
+		case <-settle:
+			ev := RebindEvent{
+				Reason: strings.Join(reasons, ", "),
+				Err:    pinger.Rebind(),
+			}
+			reasons, settle = nil, nil
+
+			pinger.logger().Info("sockets rebound", "reason", ev.Reason, "error", ev.Err)
+			select {
+			case events <- ev:
+			default:
+			}
+		}
+	}
+}
+
+// addrWatch decides which netlink messages concern the bind addresses.
+type addrWatch struct {
+	addrs []net.IP       // specific bind addresses
+	links map[int32]bool // interfaces of interest and whether they are up
+}
+
+func newAddrWatch(binds ...string) (*addrWatch, error) {
+	w := addrWatch{links: make(map[int32]bool)}
+
+	for _, bind := range binds {
+		host, zone, _ := strings.Cut(bind, "%")
+		if zone != "" {
+			if iface, err := net.InterfaceByName(zone); err == nil {
+				w.links[int32(iface.Index)] = iface.Flags&net.FlagUp != 0
+			}
+		}
+		if ip := net.ParseIP(host); ip != nil && !ip.IsUnspecified() {
+			w.addrs = append(w.addrs, ip)
+		}
+	}
+	if len(w.addrs) == 0 && len(w.links) == 0 {
+		return nil, errors.New("no specific bind address to watch")
+	}
+
+	// find the interfaces currently holding the addresses
+	ifaces, _ := net.Interfaces()
+	for _, iface := range ifaces {
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && w.matches(ipnet.IP) {
+				w.links[int32(iface.Index)] = iface.Flags&net.FlagUp != 0
+			}
+		}
+	}
+
+	return &w, nil
+}
+
+func (w *addrWatch) matches(ip net.IP) bool {
+	for _, addr := range w.addrs {
+		if addr.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// handle evaluates a netlink message and returns the reason for
+// rebinding, or an empty string if the message is irrelevant.
+func (w *addrWatch) handle(m *syscall.NetlinkMessage) string {
+	switch m.Header.Type {
+	case syscall.RTM_NEWADDR, syscall.RTM_DELADDR:
+		if len(m.Data) < syscall.SizeofIfAddrmsg {
+			return ""
+		}
+		index := int32(binary.NativeEndian.Uint32(m.Data[4:8]))
+
+		attrs, err := syscall.ParseNetlinkRouteAttr(m)
+		if err != nil {
+			return ""
+		}
+		for _, attr := range attrs {
+			if attr.Attr.Type != syscall.IFA_ADDRESS && attr.Attr.Type != syscall.IFA_LOCAL {
+				continue
+			}
+			ip := net.IP(attr.Value)
+			if !w.matches(ip) {
+				continue
+			}
+			if m.Header.Type == syscall.RTM_DELADDR {
+				return fmt.Sprintf("address %s removed", ip)
+			}
+			if _, known := w.links[index]; !known {
+				w.links[index] = true
+			}
+			return fmt.Sprintf("address %s added", ip)
+		}
+
+	case syscall.RTM_NEWLINK:
+		if len(m.Data) < syscall.SizeofIfInfomsg {
+			return ""
+		}
+		index := int32(binary.NativeEndian.Uint32(m.Data[4:8]))
+		up := binary.NativeEndian.Uint32(m.Data[8:12])&syscall.IFF_UP != 0
+
+		wasUp, known := w.links[index]
+		if !known {
+			return ""
+		}
+		w.links[index] = up
+		if up && !wasUp {
+			return fmt.Sprintf("link %d up", index)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,68 @@
+package ping
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchAddresses(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	const addr = "198.51.100.7"
+	nsPath, ip := testNamespace(t, "goping-watch", addr)
+
+	pinger, err := NewInNamespace(nsPath, addr, "")
+	require.NoError(err)
+	defer pinger.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := pinger.WatchAddresses(ctx)
+	require.NoError(err)
+
+	next := func() RebindEvent {
+		select {
+		case ev := <-events:
+			return ev
+		case <-time.After(2 * time.Second):
+			t.Fatal("no rebind event")
+			return RebindEvent{}
+		}
+	}
+
+	require.NoError(ip("addr", "del", addr+"/32", "dev", "lo"))
+	ev := next()
+	assert.Equal("address "+addr+" removed", ev.Reason)
+	assert.Error(ev.Err)
+	assert.Error(pinger.Err())
+
+	require.NoError(ip("addr", "add", addr+"/32", "dev", "lo"))
+	ev = next()
+	assert.Equal("address "+addr+" added", ev.Reason)
+	assert.NoError(ev.Err)
+	assert.NoError(pinger.Err())
+
+	_, err = pinger.Ping(&net.IPAddr{IP: net.ParseIP(addr)}, time.Second)
+	assert.NoError(err)
+
+	cancel()
+	for range events {
+		// drain until closed
+	}
+}
+
+func TestNewAddrWatch(t *testing.T) {
+	_, err := newAddrWatch("0.0.0.0", "::")
+	require.EqualError(t, err, "no specific bind address to watch")
+
+	w, err := newAddrWatch("192.0.2.1", "")
+	require.NoError(t, err)
+	assert.True(t, w.matches(net.ParseIP("192.0.2.1")))
+	assert.False(t, w.matches(net.ParseIP("192.0.2.2")))
+}
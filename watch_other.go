@@ -0,0 +1,13 @@
+//go:build !linux
+
+package ping
+
+import (
+	"context"
+	"errors"
+)
+
+// WatchAddresses is only supported on Linux.
+func (pinger *Pinger) WatchAddresses(ctx context.Context) (<-chan RebindEvent, error) {
+	return nil, errors.New("watching addresses is not supported on this platform")
+}